@@ -3,9 +3,16 @@ package slab
 import (
 	"fmt"
 	"image"
+	"image/color"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gobold"
 	"golang.org/x/image/font/gofont/gobolditalic"
 	"golang.org/x/image/font/gofont/goitalic"
@@ -17,18 +24,101 @@ import (
 	"golang.org/x/image/font/opentype"
 )
 
-type Margins struct{ Left, Right, Top, Bottom float64 }
+/* Length is a fraction of the slide-dimension, or absolute px */
+type Length struct {
+	Value  float64
+	Pixels bool
+}
+
+/* of returns the length in px relative to `dim` px */
+func (l Length) of(dim int) int {
+	if l.Pixels {
+		return int(l.Value)
+	}
+	return int(float64(dim) * l.Value)
+}
+
+/* fraction returns the relative part of the length, 0 for absolute lengths */
+func (l Length) fraction() float64 {
+	if l.Pixels {
+		return 0
+	}
+	return l.Value
+}
+
+type Margins struct{ Left, Right, Top, Bottom Length }
+
+/* uniformMargins returns margins of `l` on every side */
+func uniformMargins(l Length) Margins {
+	return Margins{l, l, l, l}
+}
 
 /* Apply applies the margin-boundaries to `r` and returns a copy */
 func (m Margins) Apply(r image.Rectangle) image.Rectangle {
 	w, h := r.Dx(), r.Dy()
-	r.Min.X += int(float64(w) * m.Left)
-	r.Min.Y += int(float64(h) * m.Top)
-	r.Max.X -= int(float64(w) * m.Right)
-	r.Max.Y -= int(float64(h) * m.Bottom)
+	r.Min.X += m.Left.of(w)
+	r.Min.Y += m.Top.of(h)
+	r.Max.X -= m.Right.of(w)
+	r.Max.Y -= m.Bottom.of(h)
+	/* collapse onto the center rather than inverting */
+	if r.Min.X > r.Max.X {
+		r.Min.X = (r.Min.X + r.Max.X) / 2
+		r.Max.X = r.Min.X
+	}
+	if r.Min.Y > r.Max.Y {
+		r.Min.Y = (r.Min.Y + r.Max.Y) / 2
+		r.Max.Y = r.Min.Y
+	}
 	return r
 }
 
+/* overflows reports whether opposing relative margins leave no space, absolute margins depend on the slide-size */
+func (m Margins) overflows() bool {
+	return m.Left.fraction()+m.Right.fraction() >= 1 || m.Top.fraction()+m.Bottom.fraction() >= 1
+}
+
+/* parseLength parses `value` as `Npx` or as percentage, the percent-sign is optional */
+func parseLength(value string) (Length, error) {
+	if px, ok := strings.CutSuffix(value, "px"); ok {
+		n, err := strconv.Atoi(px)
+		if err != nil {
+			return Length{}, err
+		}
+		return Length{Value: float64(n), Pixels: true}, nil
+	}
+	value = strings.TrimSuffix(value, "%")
+	pct, err := strconv.Atoi(value)
+	if err != nil {
+		return Length{}, err
+	}
+	return Length{Value: float64(pct) / 100}, nil
+}
+
+/* parseMargins parses CSS-style `<all>`, `<vertical> <horizontal>`, `<top> <horizontal> <bottom>` or `<top> <right> <bottom> <left>` */
+func parseMargins(value string) (Margins, error) {
+	fields := strings.Fields(value)
+	lens := make([]Length, len(fields))
+	for i, field := range fields {
+		l, err := parseLength(field)
+		if err != nil {
+			return Margins{}, err
+		}
+		lens[i] = l
+	}
+	switch len(lens) {
+	case 1:
+		return uniformMargins(lens[0]), nil
+	case 2:
+		return Margins{Left: lens[1], Right: lens[1], Top: lens[0], Bottom: lens[0]}, nil
+	case 3:
+		return Margins{Left: lens[1], Right: lens[1], Top: lens[0], Bottom: lens[2]}, nil
+	case 4:
+		return Margins{Left: lens[3], Right: lens[1], Top: lens[0], Bottom: lens[2]}, nil
+	default:
+		return Margins{}, fmt.Errorf("expected 1 to 4 values, got %d", len(lens))
+	}
+}
+
 type Alignment int
 
 const (
@@ -45,6 +135,141 @@ const (
 	Bottom
 )
 
+type TransitionKind int
+
+const (
+	NoTransition TransitionKind = iota
+	Fade
+)
+
+/* Transition describes how a slide is entered */
+type Transition struct {
+	Kind     TransitionKind
+	Duration time.Duration
+}
+
+type ImageScaling int
+
+const (
+	ScaleBiLinear ImageScaling = iota
+	ScaleNearest
+	ScaleCatmullRom
+)
+
+type BgOrder int
+
+const (
+	BgCycle  BgOrder = iota /* images of bg-folder in order of their names */
+	BgRandom                /* a random image of bg-folder per slide */
+)
+
+type Baseline int
+
+const (
+	BaselineAlign  Baseline = iota /* runs of a line share its baseline */
+	BaselineCenter                 /* smaller runs are centered on the x-height of the largest */
+)
+
+type ImageFit int
+
+const (
+	FitContain ImageFit = iota /* scale to fit entirely, letterbox the rest */
+	FitCover                   /* scale to fill the box, crop the overflow */
+	FitStretch                 /* scale to the box, ignoring the aspect-ratio */
+)
+
+/* Anchor is the part of an over-sized image kept when cropping */
+type Anchor struct {
+	Align  Alignment
+	VAlign VerticalAlignment
+}
+
+/* parseAnchor parses `center` or `<top|bottom>-<left|right>`, either side may be omitted */
+func parseAnchor(value string) (Anchor, error) {
+	anchor := Anchor{Center, Middle}
+	if value == "center" {
+		return anchor, nil
+	}
+	vert, horiz, hasHoriz := strings.Cut(value, "-")
+	if !hasHoriz {
+		switch vert {
+		case "left", "right":
+			vert, horiz = "", vert
+		}
+	}
+	switch vert {
+	case "":
+	case "top":
+		anchor.VAlign = Top
+	case "bottom":
+		anchor.VAlign = Bottom
+	default:
+		return Anchor{}, fmt.Errorf("invalid anchor `%s`", value)
+	}
+	switch horiz {
+	case "":
+	case "left":
+		anchor.Align = Left
+	case "right":
+		anchor.Align = Right
+	default:
+		return Anchor{}, fmt.Errorf("invalid anchor `%s`", value)
+	}
+	return anchor, nil
+}
+
+type FontOverflow int
+
+const (
+	OverflowClip FontOverflow = iota /* cut off at the text-box */
+	OverflowWarn                     /* draw beyond the text-box and report it */
+)
+
+type FontUnit int
+
+const (
+	FontPercent FontUnit = iota /* percent of diagonal px */
+	FontPoint
+	FontPixel
+)
+
+/* parseFontSize parses `N%`, `Npt` or `Npx` */
+func parseFontSize(value string) (float64, FontUnit, error) {
+	unit := FontPercent
+	switch {
+	case strings.HasSuffix(value, "pt"):
+		unit = FontPoint
+		value = strings.TrimSuffix(value, "pt")
+	case strings.HasSuffix(value, "px"):
+		unit = FontPixel
+		value = strings.TrimSuffix(value, "px")
+	default:
+		value = strings.TrimSuffix(value, "%")
+	}
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if size <= 0 {
+		return 0, 0, fmt.Errorf("font-size must be positive")
+	}
+	return size, unit, nil
+}
+
+/* points converts `size` in `unit` to points for text inside `bounds` */
+func (c PresConfig) points(size float64, unit FontUnit, bounds image.Rectangle) float64 {
+	switch unit {
+	case FontPercent:
+		area := float64(bounds.Dx()*bounds.Dx() + bounds.Dy()*bounds.Dy())
+		return size * math.Sqrt(area) / 100 * 72 / c.DPI
+	case FontPixel:
+		return size * 72 / c.DPI
+	default:
+		/* faces take points and scale them by DPI */
+		return size
+	}
+}
+
 type FontCollection struct {
 	Regular    *opentype.Font
 	Bold       *opentype.Font
@@ -54,16 +279,118 @@ type FontCollection struct {
 
 type PresConfig struct {
 	Foreground     image.Image /* uniform */
-	Background     image.Image /* uniform */
+	AutoForeground bool        /* derive Foreground from Background */
+	Outline        image.Image /* halo around glyphs, nil for none */
+	TextOpacity    float64     /* multiplies the alpha of text, 0 to 1 */
+	Background     image.Image /* uniform, or an image of BgFolder once parsed */
 	Fonts          FontCollection
 	MonoFonts      FontCollection
-	Margin         Margins
+	Margin         Margins /* outer frame of the slide */
+	Padding        Margins /* inner space of each content column */
 	Align          Alignment
 	VAlign         VerticalAlignment
+	WritingMode    WritingMode
+	RotateLatin    bool /* turn non-CJK glyphs sideways in vertical text instead of keeping them upright */
 	TabSize        int
+	TabStops       bool /* advance tabs to the next multiple of TabSize spaces */
 	NewlineSpacing float64
+	LineJoin       LineJoin /* how consecutive lines of the source are joined */
+	MaxBlankLines  int      /* consecutive blank lines kept as paragraph-breaks, 0 for all */
+	TrimLeading    bool     /* drop blank lines before the text of a block */
+	InlineMath     bool     /* set `$...$` within text as math, `$$...$$` always is */
 	BigText        float64
-	FontSize       float64 /* percent of diagonal px */
+	TitleTracking  float64  /* extra space after every glyph of BigText, in em */
+	Baseline       Baseline /* placement of runs of different sizes on a line */
+	FontSize       float64  /* 0 to fit the text to the slide */
+	FontUnit       FontUnit
+	MinFontSize    float64 /* lower bound of fitted text, 0 for none */
+	MinFontUnit    FontUnit
+	MaxFontSize    float64 /* upper bound of fitted text, 0 for none */
+	MaxFontUnit    FontUnit
+	FontOverflow   FontOverflow /* text not fitting at MinFontSize */
+	FontGroup      string       /* fitted text of a group shares the smallest size, "" for none */
+	DPI            float64
+	Transition     Transition
+	DropCap        int         /* lines spanned by an enlarged initial, 0 to disable */
+	FinalSlide     bool        /* append an "End of Presentation"-slide, only read from %set */
+	FinalText      string      /* markup of the final slide, only read from %set */
+	FinalFg        image.Image /* only read from %set */
+	FinalBg        image.Image /* only read from %set */
+	PresenterNext  int         /* upcoming slides previewed by the presenter, only read from %set */
+	Kerning        bool
+	Hinting        font.Hinting /* snapping of outlines to the pixel-grid */
+	Widows         bool         /* avoid paragraphs ending in a single word */
+	Hyphenate      string       /* language to hyphenate long words in, "" for none */
+	ImageScaling   ImageScaling
+	ImageFit       ImageFit
+	ImageAnchor    *Anchor /* part of the image kept by FitCover, nil follows align/valign */
+
+	ImageRadius      float64     /* corner radius as fraction of the shorter side */
+	ImageBorder      image.Image /* nil for no border */
+	ImageBorderWidth int         /* px */
+	ImageBackground  image.Image /* fill around contained images, nil for none */
+
+	ImageShadow       image.Image /* drop shadow behind images, nil for none */
+	ImageShadowOffset int         /* px down and to the right */
+	ImageShadowBlur   int         /* px */
+
+	ParagraphSpacing   float64 /* extra space between a line of big text and the text below, in em */
+	UnderlineThickness float64 /* fraction of font height */
+	StrikeThickness    float64 /* fraction of font height */
+	StrikePosition     float64 /* fraction of ascent above the baseline */
+
+	PresenterLayout PresenterLayout /* only read from %set */
+	WindowSize      image.Point     /* initial size of the viewer-window in px, only read from %set */
+
+	Watermark        image.Image /* logo drawn on top of every slide, nil for none */
+	WatermarkAnchor  Anchor      /* corner of the slide holding the watermark */
+	WatermarkSize    float64     /* fraction of the slide */
+	WatermarkOpacity float64     /* 0 to 1 */
+
+	Duration time.Duration /* time the slide is shown in exports, 0 for their default */
+
+	BgFolder []*coverFill /* backgrounds assigned to the slides in turn, nil for none */
+	BgOrder  BgOrder
+	BgSeed   uint64 /* seed of BgRandom, 0 for a different order every time */
+
+	QRLevel QRLevel /* error-correction of the following %qr codes */
+	QRSize  float64 /* side of %qr codes as fraction of the shorter side of the block */
+
+	ProgressBar   bool        /* thin bar along the bottom showing the position in the presentation */
+	ProgressColor image.Image /* fill of the progress-bar */
+
+	Header string /* markup drawn in a band above the content, "" for none */
+	Footer string /* markup drawn in a band below the content, "" for none */
+
+	AttrColors map[MarkupAttribute]image.Image /* fill per markup-attribute, shared between copies: clone before writing */
+}
+
+/* attrColorKeys maps the `<name>-color` attributes to their markup-attribute, in order of precedence */
+var attrColorKeys = []struct {
+	key  string
+	attr MarkupAttribute
+}{
+	{"code-color", Code},
+	{"bold-color", Bold},
+	{"italic-color", Italic},
+	{"underline-color", Underline},
+	{"strike-color", Strikethrough},
+	{"big-color", BigText},
+	{"nowrap-color", NoWrap},
+	{"footnote-color", Footnote},
+	{"smallcaps-color", Smallcaps},
+}
+
+/* fill returns the color for text with attributes `a`, Foreground unless mapped by a `<name>-color` attribute */
+func (c PresConfig) fill(a MarkupAttribute) image.Image {
+	for _, ac := range attrColorKeys {
+		if a.has(ac.attr) {
+			if fill, ok := c.AttrColors[ac.attr]; ok {
+				return fill
+			}
+		}
+	}
+	return c.Foreground
 }
 
 func (c *PresConfig) AddAttribute(str string) error {
@@ -73,88 +400,240 @@ func (c *PresConfig) AddAttribute(str string) error {
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
+		if value == "auto" {
+			c.AutoForeground = true
+			c.Foreground = contrastColor(c.Background)
+			break
+		}
 		color, err := parseColor(value)
 		if err != nil {
 			return fmt.Errorf("error in `%s`: %w", value, err)
 		}
 		c.Foreground = image.NewUniform(color)
+		c.AutoForeground = false
+	case "fg-gradient":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		parts := strings.Split(value, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("expected `<from>,<to>[,<direction>]`")
+		}
+		from, err := parseColor(parts[0])
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", parts[0], err)
+		}
+		to, err := parseColor(parts[1])
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", parts[1], err)
+		}
+		grad := &Gradient{From: from, To: to}
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "horizontal":
+				grad.Direction = GradientHorizontal
+			case "vertical":
+				grad.Direction = GradientVertical
+			case "diagonal":
+				grad.Direction = GradientDiagonal
+			default:
+				return fmt.Errorf("invalid direction `%s`", parts[2])
+			}
+		}
+		c.Foreground = grad
+		c.AutoForeground = false
+	case "fg-image":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		slide, err := NewImageSlide(value)
+		if err != nil {
+			return err
+		}
+		if err := slide.Decode(); err != nil {
+			return err
+		}
+		c.Foreground = &textureFill{slide.src}
+		c.AutoForeground = false
+	case "text-opacity":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		opacity, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		c.TextOpacity = min(max(opacity, 0), 1)
 	case "background", "bg":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
+		if value == "transparent" || value == "none" {
+			value = "#0000"
+		}
 		color, err := parseColor(value)
 		if err != nil {
 			return fmt.Errorf("error in `%s`: %w", value, err)
 		}
 		c.Background = image.NewUniform(color)
-	case "left":
+		c.BgFolder = nil
+		if c.AutoForeground {
+			c.Foreground = contrastColor(c.Background)
+		}
+	case "bg-folder":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
-		value = strings.TrimSuffix(value, "%")
-		px, err := strconv.Atoi(value)
+		entries, err := os.ReadDir(value)
 		if err != nil {
 			return err
 		}
-		c.Margin.Left = float64(px) / 100
-	case "right":
+		var folder []*coverFill
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			/* skip files which are no images */
+			if slide, err := NewImageSlide(filepath.Join(value, entry.Name())); err == nil {
+				folder = append(folder, &coverFill{img: slide})
+			}
+		}
+		if len(folder) == 0 {
+			return fmt.Errorf("no images in `%s`", value)
+		}
+		c.BgFolder = folder
+	case "bg-order":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
-		value = strings.TrimSuffix(value, "%")
-		px, err := strconv.Atoi(value)
+		switch value {
+		case "cycle":
+			c.BgOrder = BgCycle
+		case "random":
+			c.BgOrder = BgRandom
+		default:
+			return fmt.Errorf("invalid order `%s`, expected cycle or random", value)
+		}
+	case "bg-seed":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		seed, err := strconv.ParseUint(value, 10, 64)
 		if err != nil {
 			return err
 		}
-		c.Margin.Right = float64(px) / 100
-	case "top":
+		c.BgSeed = seed
+	case "text-outline":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
-		value = strings.TrimSuffix(value, "%")
-		px, err := strconv.Atoi(value)
+		if value == "none" {
+			c.Outline = nil
+			break
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", value, err)
+		}
+		c.Outline = image.NewUniform(color)
+	case "code-color", "bold-color", "italic-color", "underline-color", "strike-color",
+		"big-color", "nowrap-color", "footnote-color", "smallcaps-color":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		var attr MarkupAttribute
+		for _, ac := range attrColorKeys {
+			if ac.key == key {
+				attr = ac.attr
+			}
+		}
+		c.AttrColors = maps.Clone(c.AttrColors)
+		if value == "none" {
+			delete(c.AttrColors, attr)
+			break
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", value, err)
+		}
+		if c.AttrColors == nil {
+			c.AttrColors = make(map[MarkupAttribute]image.Image)
+		}
+		c.AttrColors[attr] = image.NewUniform(color)
+	case "left", "pad-left":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		l, err := parseLength(value)
 		if err != nil {
 			return err
 		}
-		c.Margin.Top = float64(px) / 100
-	case "bottom":
+		m := c.Margin
+		if strings.HasPrefix(key, "pad-") {
+			m = c.Padding
+		}
+		m.Left = l
+		if err := c.setMargins(key, m); err != nil {
+			return err
+		}
+	case "right", "pad-right":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
-		value = strings.TrimSuffix(value, "%")
-		px, err := strconv.Atoi(value)
+		l, err := parseLength(value)
 		if err != nil {
 			return err
 		}
-		c.Margin.Bottom = float64(px) / 100
-	case "margin":
+		m := c.Margin
+		if strings.HasPrefix(key, "pad-") {
+			m = c.Padding
+		}
+		m.Right = l
+		if err := c.setMargins(key, m); err != nil {
+			return err
+		}
+	case "top", "pad-top":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
 		}
-		first, second, hasSecond := strings.Cut(value, " ")
-
-		first = strings.TrimSuffix(first, "%")
-		firstPx, err := strconv.Atoi(first)
+		l, err := parseLength(value)
 		if err != nil {
 			return err
 		}
-		firstPxf := float64(firstPx) / 100
-		if !hasSecond {
-			c.Margin.Left = firstPxf
-			c.Margin.Right = firstPxf
-			c.Margin.Top = firstPxf
-			c.Margin.Bottom = firstPxf
-		} else {
-			second = strings.TrimSuffix(second, "%")
-			secondPx, err := strconv.Atoi(second)
-			if err != nil {
-				return err
-			}
-			secondPxf := float64(secondPx) / 100
-			c.Margin.Left = secondPxf
-			c.Margin.Right = secondPxf
-			c.Margin.Top = firstPxf
-			c.Margin.Bottom = firstPxf
+		m := c.Margin
+		if strings.HasPrefix(key, "pad-") {
+			m = c.Padding
+		}
+		m.Top = l
+		if err := c.setMargins(key, m); err != nil {
+			return err
+		}
+	case "bottom", "pad-bottom":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		l, err := parseLength(value)
+		if err != nil {
+			return err
+		}
+		m := c.Margin
+		if strings.HasPrefix(key, "pad-") {
+			m = c.Padding
+		}
+		m.Bottom = l
+		if err := c.setMargins(key, m); err != nil {
+			return err
+		}
+	case "margin", "padding":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		m, err := parseMargins(value)
+		if err != nil {
+			return err
+		}
+		if err := c.setMargins(key, m); err != nil {
+			return err
 		}
 	case "align":
 		if !hasValue {
@@ -184,6 +663,30 @@ func (c *PresConfig) AddAttribute(str string) error {
 		default:
 			return fmt.Errorf("invalid alignment `%s`", value)
 		}
+	case "writing-mode":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "horizontal":
+			c.WritingMode = WritingHorizontal
+		case "vertical":
+			c.WritingMode = WritingVertical
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `horizontal` or `vertical`", value)
+		}
+	case "vertical-latin":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "upright":
+			c.RotateLatin = false
+		case "rotate":
+			c.RotateLatin = true
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `upright` or `rotate`", value)
+		}
 	case "tabsize":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
@@ -202,6 +705,18 @@ func (c *PresConfig) AddAttribute(str string) error {
 			return err
 		}
 		c.NewlineSpacing = times
+	case "paragraph-spacing":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		times, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if times < 0 {
+			return fmt.Errorf("invalid value `%s`, expected 0 or more", value)
+		}
+		c.ParagraphSpacing = times
 	case "bigtext":
 		if !hasValue {
 			return fmt.Errorf("`%s` requires a value", key)
@@ -211,40 +726,828 @@ func (c *PresConfig) AddAttribute(str string) error {
 			return err
 		}
 		c.BigText = times
-	default:
-		return fmt.Errorf("invalid attribute `%s`", key)
-	}
-	return nil
-}
-
-func defaultConf() PresConfig {
-	makeFace := func(data []byte) *opentype.Font {
-		font, err := opentype.Parse(data)
+	case "title-tracking":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		tracking, err := strconv.ParseFloat(strings.TrimSuffix(value, "em"), 64)
 		if err != nil {
-			panic(fmt.Errorf("unable to parse Go-font: %w", err))
+			return err
 		}
-		return font
-	}
-	return PresConfig{
-		Foreground: image.Black,
-		Background: image.White,
-		Fonts: FontCollection{
-			Regular:    makeFace(goregular.TTF),
-			Bold:       makeFace(gobold.TTF),
-			Italic:     makeFace(goitalic.TTF),
-			BoldItalic: makeFace(gobolditalic.TTF),
-		},
-		MonoFonts: FontCollection{
-			Regular:    makeFace(gomono.TTF),
-			Bold:       makeFace(gomonobold.TTF),
-			Italic:     makeFace(gomonoitalic.TTF),
-			BoldItalic: makeFace(gomonobolditalic.TTF),
-		},
-		Margin:         Margins{0.1, 0.1, 0.1, 0.1},
-		Align:          Center,
-		VAlign:         Middle,
-		TabSize:        4,
-		NewlineSpacing: 1,
-		BigText:        1.2,
+		c.TitleTracking = tracking
+	case "baseline":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "align":
+			c.Baseline = BaselineAlign
+		case "center":
+			c.Baseline = BaselineCenter
+		default:
+			return fmt.Errorf("invalid baseline `%s`, expected align or center", value)
+		}
+	case "transition":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		kind, dur, hasDur := strings.Cut(value, " ")
+		switch kind {
+		case "none":
+			c.Transition = Transition{}
+			return nil
+		case "fade":
+			c.Transition = Transition{Kind: Fade, Duration: 300 * time.Millisecond}
+		default:
+			return fmt.Errorf("invalid transition `%s`", kind)
+		}
+		if hasDur {
+			d, err := time.ParseDuration(strings.TrimSpace(dur))
+			if err != nil {
+				return err
+			}
+			c.Transition.Duration = d
+		}
+	case "duration":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return fmt.Errorf("duration must be positive")
+		}
+		c.Duration = d
+	case "dropcap":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.DropCap = 3
+		case "off":
+			c.DropCap = 0
+		default:
+			lines, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			c.DropCap = max(lines, 0)
+		}
+	case "underline-thickness", "strike-thickness", "strike-position":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		frac, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "underline-thickness":
+			c.UnderlineThickness = frac
+		case "strike-thickness":
+			c.StrikeThickness = frac
+		case "strike-position":
+			c.StrikePosition = frac
+		}
+	case "image-scaling":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "bilinear":
+			c.ImageScaling = ScaleBiLinear
+		case "nearest":
+			c.ImageScaling = ScaleNearest
+		case "catmull", "catmullrom":
+			c.ImageScaling = ScaleCatmullRom
+		default:
+			return fmt.Errorf("invalid scaling `%s`", value)
+		}
+	case "image-fit":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "contain":
+			c.ImageFit = FitContain
+		case "cover":
+			c.ImageFit = FitCover
+		case "stretch":
+			c.ImageFit = FitStretch
+		default:
+			return fmt.Errorf("invalid fit `%s`", value)
+		}
+	case "image-anchor":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		anchor, err := parseAnchor(value)
+		if err != nil {
+			return err
+		}
+		c.ImageAnchor = &anchor
+	case "image-radius":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		radius, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		c.ImageRadius = min(max(radius, 0), 0.5)
+	case "image-border":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "none" {
+			c.ImageBorder = nil
+			c.ImageBorderWidth = 0
+			break
+		}
+		col, width, hasWidth := strings.Cut(value, ",")
+		color, err := parseColor(col)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", col, err)
+		}
+		borderWidth := 1
+		if hasWidth {
+			width = strings.TrimSpace(width)
+			borderWidth, err = strconv.Atoi(width)
+			if err != nil {
+				return err
+			}
+			if borderWidth < 0 {
+				return fmt.Errorf("invalid image-border width `%s`, expected 0 or more", width)
+			}
+		}
+		c.ImageBorder, c.ImageBorderWidth = image.NewUniform(color), borderWidth
+	case "image-background":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "none" {
+			c.ImageBackground = nil
+			break
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", value, err)
+		}
+		c.ImageBackground = image.NewUniform(color)
+	case "image-shadow":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "none" {
+			c.ImageShadow = nil
+			break
+		}
+		parts := strings.Split(value, ",")
+		if len(parts) > 3 {
+			return fmt.Errorf("expected `<color>[,<offset>[,<blur>]]`")
+		}
+		color, err := parseColor(parts[0])
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", parts[0], err)
+		}
+		offset, blur := 8, 8
+		if len(parts) > 1 {
+			if offset, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+				return err
+			}
+		}
+		if len(parts) > 2 {
+			if blur, err = strconv.Atoi(strings.TrimSpace(parts[2])); err != nil {
+				return err
+			}
+		}
+		c.ImageShadow = image.NewUniform(color)
+		c.ImageShadowOffset, c.ImageShadowBlur = offset, max(blur, 0)
+	case "watermark":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "none" {
+			c.Watermark = nil
+			break
+		}
+		slide, err := NewImageSlide(value)
+		if err != nil {
+			return err
+		}
+		if err := slide.Decode(); err != nil {
+			return err
+		}
+		c.Watermark = slide.src
+	case "no-watermark":
+		c.Watermark = nil
+	case "watermark-position":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		anchor, err := parseAnchor(value)
+		if err != nil {
+			return err
+		}
+		c.WatermarkAnchor = anchor
+	case "watermark-size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return err
+		}
+		if pct <= 0 || pct > 100 {
+			return fmt.Errorf("watermark-size must be between 0 and 100%%")
+		}
+		c.WatermarkSize = pct / 100
+	case "watermark-opacity":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		opacity, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		c.WatermarkOpacity = min(max(opacity, 0), 1)
+	case "qr-level":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch strings.ToUpper(value) {
+		case "L":
+			c.QRLevel = QRLow
+		case "M":
+			c.QRLevel = QRMedium
+		case "Q":
+			c.QRLevel = QRQuartile
+		case "H":
+			c.QRLevel = QRHigh
+		default:
+			return fmt.Errorf("invalid qr-level `%s`, expected L, M, Q or H", value)
+		}
+	case "qr-size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return err
+		}
+		if pct <= 0 || pct > 100 {
+			return fmt.Errorf("qr-size must be between 0 and 100%%")
+		}
+		c.QRSize = pct / 100
+	case "progress-bar":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.ProgressBar = true
+		case "off":
+			c.ProgressBar = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "progress-color":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", value, err)
+		}
+		c.ProgressColor = image.NewUniform(color)
+	case "header", "footer":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "none" {
+			value = ""
+		}
+		if key == "header" {
+			c.Header = value
+		} else {
+			c.Footer = value
+		}
+	case "presenter-next":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("presenter-next must be at least 1")
+		}
+		c.PresenterNext = n
+	case "presenter-layout":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "split":
+			c.PresenterLayout = LayoutSplit
+		case "notes-right":
+			c.PresenterLayout = LayoutNotesRight
+		case "current-large":
+			c.PresenterLayout = LayoutCurrentLarge
+		default:
+			return fmt.Errorf("invalid presenter-layout `%s`", value)
+		}
+	case "window", "size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		w, h, ok := strings.Cut(value, "x")
+		if !ok {
+			return fmt.Errorf("invalid %s `%s`, expected WIDTHxHEIGHT", key, value)
+		}
+		width, err := strconv.Atoi(w)
+		if err != nil {
+			return err
+		}
+		height, err := strconv.Atoi(h)
+		if err != nil {
+			return err
+		}
+		if width < 1 || height < 1 {
+			return fmt.Errorf("%s must be positive", key)
+		}
+		c.WindowSize = image.Pt(width, height)
+	case "kerning":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.Kerning = true
+		case "off":
+			c.Kerning = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "trim-leading":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.TrimLeading = true
+		case "off":
+			c.TrimLeading = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "inline-math":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.InlineMath = true
+		case "off":
+			c.InlineMath = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "max-blank-lines":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "all" {
+			c.MaxBlankLines = 0
+			break
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid max-blank-lines `%s`, expected a positive number or `all`", value)
+		}
+		c.MaxBlankLines = n
+	case "line-join":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "space":
+			c.LineJoin = LineJoinSpace
+		case "break":
+			c.LineJoin = LineJoinBreak
+		default:
+			return fmt.Errorf("invalid line-join `%s`, expected space or break", value)
+		}
+	case "hinting":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "none":
+			c.Hinting = font.HintingNone
+		case "vertical":
+			c.Hinting = font.HintingVertical
+		case "full":
+			c.Hinting = font.HintingFull
+		default:
+			return fmt.Errorf("invalid hinting `%s`, expected none, vertical or full", value)
+		}
+	case "widows":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.Widows = true
+		case "off":
+			c.Widows = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "hyphenate":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "off" {
+			c.Hyphenate = ""
+			break
+		}
+		if _, ok := hyphenators[value]; !ok {
+			return fmt.Errorf("unsupported language `%s`", value)
+		}
+		c.Hyphenate = value
+	case "tabs":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "stops":
+			c.TabStops = true
+		case "spaces":
+			c.TabStops = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `stops` or `spaces`", value)
+		}
+	case "final-slide":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "on":
+			c.FinalSlide = true
+		case "off":
+			c.FinalSlide = false
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `on` or `off`", value)
+		}
+	case "final-text":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		c.FinalText = value
+	case "final-fg", "final-bg":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return fmt.Errorf("error in `%s`: %w", value, err)
+		}
+		if key == "final-fg" {
+			c.FinalFg = image.NewUniform(color)
+		} else {
+			c.FinalBg = image.NewUniform(color)
+		}
+	case "dpi":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		dpi, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		if dpi <= 0 {
+			return fmt.Errorf("dpi must be positive")
+		}
+		c.DPI = dpi
+	case "font-size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		if value == "auto" {
+			c.FontSize = 0
+			c.FontUnit = FontPercent
+			break
+		}
+		size, unit, err := parseFontSize(value)
+		if err != nil {
+			return err
+		}
+		c.FontSize = size
+		c.FontUnit = unit
+	case "min-font-size", "max-font-size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		var size float64
+		var unit FontUnit
+		if value != "none" {
+			var err error
+			size, unit, err = parseFontSize(value)
+			if err != nil {
+				return err
+			}
+		}
+		if key == "min-font-size" {
+			c.MinFontSize, c.MinFontUnit = size, unit
+		} else {
+			c.MaxFontSize, c.MaxFontUnit = size, unit
+		}
+	case "uniform-font-size":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		c.FontGroup = value
+		if value == "none" {
+			c.FontGroup = ""
+		}
+	case "font-overflow":
+		if !hasValue {
+			return fmt.Errorf("`%s` requires a value", key)
+		}
+		switch value {
+		case "clip":
+			c.FontOverflow = OverflowClip
+		case "warn":
+			c.FontOverflow = OverflowWarn
+		default:
+			return fmt.Errorf("invalid value `%s`, expected `clip` or `warn`", value)
+		}
+	default:
+		return fmt.Errorf("invalid attribute `%s`", key)
+	}
+	return nil
+}
+
+/* setMargins assigns `m` to the padding for `padding` and `pad-`-keys, otherwise to the margin, unless opposing sides exceed 100% */
+func (c *PresConfig) setMargins(key string, m Margins) error {
+	if key == "padding" || strings.HasPrefix(key, "pad-") {
+		if m.overflows() {
+			return fmt.Errorf("opposing paddings exceed 100%%")
+		}
+		c.Padding = m
+		return nil
+	}
+	if m.overflows() {
+		return fmt.Errorf("opposing margins exceed 100%%")
+	}
+	c.Margin = m
+	return nil
+}
+
+/* ResetAttribute restores the attribute `key` to its value in `def` */
+func (c *PresConfig) ResetAttribute(key string, def PresConfig) error {
+	margin, padding := c.Margin, c.Padding
+	switch key {
+	case "foreground", "fg", "fg-gradient", "fg-image":
+		c.Foreground, c.AutoForeground = def.Foreground, def.AutoForeground
+	case "background", "bg":
+		c.Background = def.Background
+		c.BgFolder = def.BgFolder
+		if c.AutoForeground {
+			c.Foreground = contrastColor(c.Background)
+		}
+	case "bg-folder":
+		c.BgFolder = def.BgFolder
+	case "bg-order":
+		c.BgOrder = def.BgOrder
+	case "bg-seed":
+		c.BgSeed = def.BgSeed
+	case "text-opacity":
+		c.TextOpacity = def.TextOpacity
+	case "text-outline":
+		c.Outline = def.Outline
+	case "code-color", "bold-color", "italic-color", "underline-color", "strike-color",
+		"big-color", "nowrap-color", "footnote-color", "smallcaps-color":
+		for _, ac := range attrColorKeys {
+			if ac.key != key {
+				continue
+			}
+			c.AttrColors = maps.Clone(c.AttrColors)
+			if fill, ok := def.AttrColors[ac.attr]; ok {
+				if c.AttrColors == nil {
+					c.AttrColors = make(map[MarkupAttribute]image.Image)
+				}
+				c.AttrColors[ac.attr] = fill
+			} else {
+				delete(c.AttrColors, ac.attr)
+			}
+		}
+	case "left":
+		c.Margin.Left = def.Margin.Left
+	case "right":
+		c.Margin.Right = def.Margin.Right
+	case "top":
+		c.Margin.Top = def.Margin.Top
+	case "bottom":
+		c.Margin.Bottom = def.Margin.Bottom
+	case "margin":
+		c.Margin = def.Margin
+	case "pad-left":
+		c.Padding.Left = def.Padding.Left
+	case "pad-right":
+		c.Padding.Right = def.Padding.Right
+	case "pad-top":
+		c.Padding.Top = def.Padding.Top
+	case "pad-bottom":
+		c.Padding.Bottom = def.Padding.Bottom
+	case "padding":
+		c.Padding = def.Padding
+	case "align":
+		c.Align = def.Align
+	case "valign":
+		c.VAlign = def.VAlign
+	case "writing-mode":
+		c.WritingMode = def.WritingMode
+	case "vertical-latin":
+		c.RotateLatin = def.RotateLatin
+	case "tabsize":
+		c.TabSize = def.TabSize
+	case "tabs":
+		c.TabStops = def.TabStops
+	case "newline-spacing":
+		c.NewlineSpacing = def.NewlineSpacing
+	case "paragraph-spacing":
+		c.ParagraphSpacing = def.ParagraphSpacing
+	case "bigtext":
+		c.BigText = def.BigText
+	case "title-tracking":
+		c.TitleTracking = def.TitleTracking
+	case "baseline":
+		c.Baseline = def.Baseline
+	case "transition":
+		c.Transition = def.Transition
+	case "duration":
+		c.Duration = def.Duration
+	case "dropcap":
+		c.DropCap = def.DropCap
+	case "underline-thickness":
+		c.UnderlineThickness = def.UnderlineThickness
+	case "strike-thickness":
+		c.StrikeThickness = def.StrikeThickness
+	case "strike-position":
+		c.StrikePosition = def.StrikePosition
+	case "image-scaling":
+		c.ImageScaling = def.ImageScaling
+	case "image-fit":
+		c.ImageFit = def.ImageFit
+	case "image-anchor":
+		c.ImageAnchor = def.ImageAnchor
+	case "image-radius":
+		c.ImageRadius = def.ImageRadius
+	case "image-border":
+		c.ImageBorder, c.ImageBorderWidth = def.ImageBorder, def.ImageBorderWidth
+	case "image-background":
+		c.ImageBackground = def.ImageBackground
+	case "image-shadow":
+		c.ImageShadow, c.ImageShadowOffset, c.ImageShadowBlur = def.ImageShadow, def.ImageShadowOffset, def.ImageShadowBlur
+	case "watermark", "no-watermark":
+		c.Watermark = def.Watermark
+	case "watermark-position":
+		c.WatermarkAnchor = def.WatermarkAnchor
+	case "watermark-size":
+		c.WatermarkSize = def.WatermarkSize
+	case "watermark-opacity":
+		c.WatermarkOpacity = def.WatermarkOpacity
+	case "qr-level":
+		c.QRLevel = def.QRLevel
+	case "qr-size":
+		c.QRSize = def.QRSize
+	case "progress-bar":
+		c.ProgressBar = def.ProgressBar
+	case "progress-color":
+		c.ProgressColor = def.ProgressColor
+	case "header":
+		c.Header = def.Header
+	case "footer":
+		c.Footer = def.Footer
+	case "presenter-next":
+		c.PresenterNext = def.PresenterNext
+	case "presenter-layout":
+		c.PresenterLayout = def.PresenterLayout
+	case "window", "size":
+		c.WindowSize = def.WindowSize
+	case "kerning":
+		c.Kerning = def.Kerning
+	case "max-blank-lines":
+		c.MaxBlankLines = def.MaxBlankLines
+	case "trim-leading":
+		c.TrimLeading = def.TrimLeading
+	case "inline-math":
+		c.InlineMath = def.InlineMath
+	case "line-join":
+		c.LineJoin = def.LineJoin
+	case "hinting":
+		c.Hinting = def.Hinting
+	case "widows":
+		c.Widows = def.Widows
+	case "hyphenate":
+		c.Hyphenate = def.Hyphenate
+	case "final-slide":
+		c.FinalSlide = def.FinalSlide
+	case "final-text":
+		c.FinalText = def.FinalText
+	case "final-fg":
+		c.FinalFg = def.FinalFg
+	case "final-bg":
+		c.FinalBg = def.FinalBg
+	case "dpi":
+		c.DPI = def.DPI
+	case "font-size":
+		c.FontSize, c.FontUnit = def.FontSize, def.FontUnit
+	case "min-font-size":
+		c.MinFontSize, c.MinFontUnit = def.MinFontSize, def.MinFontUnit
+	case "max-font-size":
+		c.MaxFontSize, c.MaxFontUnit = def.MaxFontSize, def.MaxFontUnit
+	case "font-overflow":
+		c.FontOverflow = def.FontOverflow
+	case "uniform-font-size":
+		c.FontGroup = def.FontGroup
+	default:
+		return fmt.Errorf("invalid attribute `%s`", key)
+	}
+	if c.Margin.overflows() {
+		c.Margin = margin
+		return fmt.Errorf("opposing margins exceed 100%%")
+	}
+	if c.Padding.overflows() {
+		c.Padding = padding
+		return fmt.Errorf("opposing paddings exceed 100%%")
+	}
+	return nil
+}
+
+/* pixels converts a font-size in points to pixels */
+func (c PresConfig) pixels(size float64) float64 {
+	return size * c.DPI / 72
+}
+
+func defaultConf() PresConfig {
+	makeFace := func(data []byte) *opentype.Font {
+		font, err := opentype.Parse(data)
+		if err != nil {
+			panic(fmt.Errorf("unable to parse Go-font: %w", err))
+		}
+		return font
+	}
+	return PresConfig{
+		Foreground:  image.Black,
+		Background:  image.White,
+		TextOpacity: 1,
+		Fonts: FontCollection{
+			Regular:    makeFace(goregular.TTF),
+			Bold:       makeFace(gobold.TTF),
+			Italic:     makeFace(goitalic.TTF),
+			BoldItalic: makeFace(gobolditalic.TTF),
+		},
+		MonoFonts: FontCollection{
+			Regular:    makeFace(gomono.TTF),
+			Bold:       makeFace(gomonobold.TTF),
+			Italic:     makeFace(gomonoitalic.TTF),
+			BoldItalic: makeFace(gomonobolditalic.TTF),
+		},
+		Margin:         uniformMargins(Length{Value: 0.1}),
+		Align:          Center,
+		VAlign:         Middle,
+		TabSize:        4,
+		NewlineSpacing: 1,
+		MaxBlankLines:  1,
+		TrimLeading:    true,
+		DPI:            72,
+		BigText:        1.2,
+		FinalSlide:     true,
+		FinalText:      "**End of Presentation**",
+		FinalFg:        image.NewUniform(color.Gray{200}),
+		FinalBg:        image.NewUniform(color.Gray{50}),
+		PresenterNext:  1,
+		WindowSize:     image.Pt(800, 600),
+		Kerning:        true,
+
+		ProgressColor: image.NewUniform(color.RGBA{230, 160, 0, 255}),
+
+		WatermarkAnchor:  Anchor{Right, Bottom},
+		WatermarkSize:    0.1,
+		WatermarkOpacity: 1,
+
+		QRLevel: QRMedium,
+		QRSize:  1,
+
+		UnderlineThickness: 0.05,
+		StrikeThickness:    0.05,
+		StrikePosition:     1.0 / 3,
 	}
 }
@@ -0,0 +1,84 @@
+package slab
+
+import "testing"
+
+func TestOverflowingMarginsKeepConfig(t *testing.T) {
+	tests := []string{"left=95", "right=95%", "top=90", "bottom=90", "margin=60", "pad-left=100", "pad-bottom=100", "padding=50"}
+	for _, attr := range tests {
+		c := defaultConf()
+		margin, padding := c.Margin, c.Padding
+		if err := c.AddAttribute(attr); err == nil {
+			t.Errorf("%s: no error", attr)
+		}
+		if c.Margin != margin || c.Padding != padding {
+			t.Errorf("%s: margin %v and padding %v changed, want %v and %v", attr, c.Margin, c.Padding, margin, padding)
+		}
+	}
+}
+
+func TestMaxBlankLinesValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"1", 1, true},
+		{"3", 3, true},
+		{"all", 0, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"many", 0, false},
+	}
+	for _, tt := range tests {
+		c := defaultConf()
+		err := c.AddAttribute("max-blank-lines=" + tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.value, err)
+			continue
+		}
+		if tt.ok && c.MaxBlankLines != tt.want {
+			t.Errorf("%s: %d, want %d", tt.value, c.MaxBlankLines, tt.want)
+		}
+	}
+}
+
+func TestImageBorder(t *testing.T) {
+	tests := []struct {
+		value string
+		width int
+		ok    bool
+	}{
+		{"red", 1, true},
+		{"red, 4", 4, true},
+		{"red,0", 0, true},
+		{"red, -2", 0, false},
+		{"red, wide", 0, false},
+		{"none", 0, true},
+	}
+	for _, tt := range tests {
+		c := defaultConf()
+		err := c.AddAttribute("image-border=" + tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.value, err)
+			continue
+		}
+		if !tt.ok && (c.ImageBorder != nil || c.ImageBorderWidth != 0) {
+			t.Errorf("%s: border set to %d despite the error", tt.value, c.ImageBorderWidth)
+		}
+		if tt.ok && c.ImageBorderWidth != tt.width {
+			t.Errorf("%s: width %d, want %d", tt.value, c.ImageBorderWidth, tt.width)
+		}
+	}
+}
+
+func TestParagraphSpacingValue(t *testing.T) {
+	for _, value := range []string{"-1", "-0.5"} {
+		c := defaultConf()
+		if err := c.AddAttribute("paragraph-spacing=" + value); err == nil {
+			t.Errorf("paragraph-spacing=%s is no error", value)
+		}
+	}
+	if c := defaultConf(); c.AddAttribute("paragraph-spacing=0") != nil {
+		t.Error("paragraph-spacing=0 is an error")
+	}
+}
@@ -0,0 +1,244 @@
+package slab
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type ChartKind int
+
+const (
+	ChartBar ChartKind = iota
+	ChartLine
+	ChartPie
+)
+
+/* chartPalette colors pie-slices without an explicit color */
+var chartPalette = []color.Color{
+	color.RGBA{0x4e, 0x79, 0xa7, 0xff},
+	color.RGBA{0xf2, 0x8e, 0x2b, 0xff},
+	color.RGBA{0xe1, 0x57, 0x59, 0xff},
+	color.RGBA{0x76, 0xb7, 0xb2, 0xff},
+	color.RGBA{0x59, 0xa1, 0x4f, 0xff},
+	color.RGBA{0xed, 0xc9, 0x48, 0xff},
+	color.RGBA{0xb0, 0x7a, 0xa1, 0xff},
+	color.RGBA{0x9c, 0x75, 0x5f, 0xff},
+}
+
+type ChartPoint struct {
+	Label MarkupText
+	Value float64
+	Color image.Image /* nil for the default */
+}
+
+/* Chart is a slide-content drawing the data-lines following `%chart` */
+type Chart struct {
+	Kind   ChartKind
+	Points []ChartPoint
+}
+
+func parseChartKind(value string) (ChartKind, error) {
+	switch value {
+	case "bar":
+		return ChartBar, nil
+	case "line":
+		return ChartLine, nil
+	case "pie":
+		return ChartPie, nil
+	default:
+		return 0, fmt.Errorf("invalid chart `%s`, expected bar, line or pie", value)
+	}
+}
+
+/* parseChartPoint parses `label value [color]`, ok is false if `line` is no data-line */
+func parseChartPoint(line string) (pt ChartPoint, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return pt, false
+	}
+	if len(fields) >= 3 {
+		if col, err := parseColor(fields[len(fields)-1]); err == nil {
+			if _, err := strconv.ParseFloat(fields[len(fields)-2], 64); err == nil {
+				pt.Color = image.NewUniform(col)
+				fields = fields[:len(fields)-1]
+			}
+		}
+	}
+	value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return pt, false
+	}
+	var label MarkupBuilder
+	label.Feed(strings.Join(fields[:len(fields)-1], " "))
+	pt.Label = label.Text()
+	pt.Value = value
+	return pt, true
+}
+
+/* fill returns the color of the point at `i` */
+func (c *Chart) fill(i int, attr PresConfig) image.Image {
+	if c.Points[i].Color != nil {
+		return c.Points[i].Color
+	}
+	if c.Kind == ChartPie {
+		return image.NewUniform(chartPalette[i%len(chartPalette)])
+	}
+	return attr.Foreground
+}
+
+func (c *Chart) Draw(img draw.Image, bounds image.Rectangle, attr PresConfig) {
+	if len(c.Points) == 0 {
+		return
+	}
+	bounds = attr.Padding.Apply(bounds)
+	if bounds.Empty() {
+		return
+	}
+	switch c.Kind {
+	case ChartPie:
+		c.drawPie(img, bounds, attr)
+	default:
+		c.drawAxes(img, bounds, attr)
+	}
+}
+
+/* drawLabels draws the labels in their boxes at the largest size fitting all of them */
+func (c *Chart) drawLabels(img draw.Image, boxes []image.Rectangle, attr PresConfig) {
+	cfg := attr
+	cfg.Padding = Margins{}
+	cfg.Align = Center
+	cfg.VAlign = Middle
+	cfg.DropCap = 0
+	size := math.Inf(1)
+	for i, pt := range c.Points {
+		if len(pt.Label) > 0 {
+			s, _, _ := pt.Label.fit(boxes[i], cfg)
+			size = min(size, s)
+		}
+	}
+	if math.IsInf(size, 1) || size <= 0 {
+		return
+	}
+	cfg.FontSize, cfg.FontUnit = size, FontPoint
+	cfg.MinFontSize, cfg.MaxFontSize = 0, 0
+	for i, pt := range c.Points {
+		pt.Label.Draw(img, boxes[i], cfg)
+	}
+}
+
+/* drawAxes draws a bar- or line-chart with a baseline at zero and the labels below */
+func (c *Chart) drawAxes(img draw.Image, bounds image.Rectangle, attr PresConfig) {
+	plot := bounds
+	plot.Max.Y -= bounds.Dy() / 8
+	lo, hi := 0.0, 0.0
+	for _, pt := range c.Points {
+		lo, hi = min(lo, pt.Value), max(hi, pt.Value)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	yOf := func(v float64) int {
+		return plot.Max.Y - int((v-lo)/(hi-lo)*float64(plot.Dy()))
+	}
+	slot := float64(plot.Dx()) / float64(len(c.Points))
+	thickness := max(plot.Dy()/100, 2)
+
+	labels := make([]image.Rectangle, len(c.Points))
+	var prev image.Point
+	for i, pt := range c.Points {
+		x0 := plot.Min.X + int(slot*float64(i))
+		x1 := plot.Min.X + int(slot*float64(i+1))
+		labels[i] = image.Rect(x0, plot.Max.Y, x1, bounds.Max.Y).Inset(thickness)
+		fill := c.fill(i, attr)
+		switch c.Kind {
+		case ChartBar:
+			gap := (x1 - x0) / 6
+			bar := image.Rect(x0+gap, yOf(0), x1-gap, yOf(pt.Value)).Canon()
+			draw.Draw(img, bar, fill, bar.Min, draw.Over)
+		case ChartLine:
+			p := image.Pt((x0+x1)/2, yOf(pt.Value))
+			if i > 0 {
+				drawLine(img, prev, p, thickness, fill)
+			}
+			dot := image.Rectangle{p, p}.Inset(-thickness * 2)
+			draw.Draw(img, dot, fill, dot.Min, draw.Over)
+			prev = p
+		}
+	}
+	axis := image.Rect(plot.Min.X, yOf(0)-thickness/2, plot.Max.X, yOf(0)-thickness/2+thickness)
+	draw.Draw(img, axis, attr.Foreground, axis.Min, draw.Over)
+	c.drawLabels(img, labels, attr)
+}
+
+/* drawLine draws a line from `a` to `b`, `width` px thick */
+func drawLine(img draw.Image, a, b image.Point, width int, fill image.Image) {
+	steps := max(abs(b.X-a.X), abs(b.Y-a.Y), 1)
+	for i := 0; i <= steps; i++ {
+		p := image.Pt(a.X+(b.X-a.X)*i/steps, a.Y+(b.Y-a.Y)*i/steps)
+		r := image.Rect(p.X-width/2, p.Y-width/2, p.X-width/2+width, p.Y-width/2+width)
+		draw.Draw(img, r, fill, r.Min, draw.Src)
+	}
+}
+
+/* drawPie draws a pie-chart clockwise from the top, labelled inside the slices */
+func (c *Chart) drawPie(img draw.Image, bounds image.Rectangle, attr PresConfig) {
+	var total float64
+	for _, pt := range c.Points {
+		total += max(pt.Value, 0)
+	}
+	if total <= 0 {
+		warnOnce("chart: pie without positive values")
+		return
+	}
+	radius := min(bounds.Dx(), bounds.Dy()) / 2
+	center := image.Pt((bounds.Min.X+bounds.Max.X)/2, (bounds.Min.Y+bounds.Max.Y)/2)
+
+	/* ends of the slices as fraction of the circle */
+	ends := make([]float64, len(c.Points))
+	var sum float64
+	for i, pt := range c.Points {
+		sum += max(pt.Value, 0)
+		ends[i] = sum / total
+	}
+
+	fills := make([]image.Image, len(c.Points))
+	for i := range c.Points {
+		fills[i] = c.fill(i, attr)
+	}
+	for y := -radius; y < radius; y++ {
+		for x := -radius; x < radius; x++ {
+			if x*x+y*y >= radius*radius {
+				continue
+			}
+			/* clockwise from the top */
+			frac := math.Atan2(float64(x)+0.5, -float64(y)-0.5) / (2 * math.Pi)
+			if frac < 0 {
+				frac++
+			}
+			i := 0
+			for i < len(ends)-1 && frac >= ends[i] {
+				i++
+			}
+			p := center.Add(image.Pt(x, y))
+			img.Set(p.X, p.Y, fills[i].At(p.X, p.Y))
+		}
+	}
+
+	labels := make([]image.Rectangle, len(c.Points))
+	w, h := radius*2/3, radius/5
+	for i := range c.Points {
+		begin := 0.0
+		if i > 0 {
+			begin = ends[i-1]
+		}
+		angle := (begin + ends[i]) / 2 * 2 * math.Pi
+		mid := center.Add(image.Pt(int(math.Sin(angle)*float64(radius)*0.6), int(-math.Cos(angle)*float64(radius)*0.6)))
+		labels[i] = image.Rect(mid.X-w/2, mid.Y-h/2, mid.X+w/2, mid.Y+h/2)
+	}
+	c.drawLabels(img, labels, attr)
+}
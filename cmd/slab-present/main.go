@@ -1,107 +1,60 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 
 	"github.com/friedelschoen/slab"
-	"github.com/veandco/go-sdl2/sdl"
+	"github.com/friedelschoen/slab/present"
 )
 
 func main() {
-	filename := "example.slab"
-	file, err := os.Open(filename)
-	if err != nil {
-		panic(err)
-	}
-
-	pres, err := slab.ParsePresentation(file)
-	if err != nil {
-		panic(err)
+	profiles := flag.String("profile", "", "comma-separated profiles enabling `%if`-blocks")
+	serve := flag.String("serve", "", "address to serve the HTTP-remote on, e.g. `:8080`")
+	token := flag.String("token", "", "token required by the HTTP-remote to navigate")
+	live := flag.String("web", "", "address to serve a live view of the slides on, e.g. `:8080`")
+	carry := flag.Bool("carry-config", false, "carry `%set`-options over into the next file")
+	strict := flag.Bool("strict", false, "fail on unknown or invalid options instead of warning")
+	lenient := flag.Bool("lenient", false, "leave out images that cannot be opened instead of failing")
+	lazy := flag.Bool("lazy-images", false, "decode images on their first draw instead of at startup")
+	onChange := flag.String("on-change", "", "program run with the slide-number whenever the shown slide changes")
+	flag.Parse()
+
+	filenames := flag.Args()
+	if len(filenames) == 0 {
+		filenames = []string{"example.slab"}
 	}
+	filename := strings.Join(filenames, " ")
 
-	sdl.Init(sdl.INIT_VIDEO)
-	defer sdl.Quit()
-
-	win, err := sdl.CreateWindow("slab - "+filename, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 800, 600, sdl.WINDOW_SHOWN)
-	if err != nil {
-		panic(err)
+	var enabled []string
+	if *profiles != "" {
+		enabled = strings.Split(*profiles, ",")
 	}
-	fullscreen := false
-
-	preswin, err := sdl.CreateWindow("slab - Presenter - "+filename, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 1000, 600, sdl.WINDOW_SHOWN)
+	pres, err := slab.ParseFiles(filenames, *carry, *strict, *lenient, enabled...)
 	if err != nil {
 		panic(err)
 	}
-
-	index := 0
-	running := true
-	for running {
-		ev := sdl.WaitEvent()
-
-		dirty := false
-		switch ev := ev.(type) {
-		case *sdl.QuitEvent:
-			running = false
-		case *sdl.WindowEvent:
-			switch ev.Event {
-			case sdl.WINDOWEVENT_CLOSE:
-				win.Destroy()
-				preswin.Destroy()
-				running = false
-			case sdl.WINDOWEVENT_RESIZED:
-				fallthrough
-			case sdl.WINDOWEVENT_EXPOSED, sdl.WINDOWEVENT_SIZE_CHANGED:
-				dirty = true
-			}
-		case *sdl.KeyboardEvent:
-			if ev.Type != sdl.KEYDOWN {
-				break
-			}
-			switch ev.Keysym.Sym {
-			case sdl.K_UP, sdl.K_LEFT:
-				if index > 0 {
-					index--
-					dirty = true
-				}
-			case sdl.K_DOWN, sdl.K_RIGHT:
-				if index < len(pres.Slides)-1 {
-					index++
-					dirty = true
-				}
-
-			case sdl.K_f:
-				if fullscreen {
-					win.SetFullscreen(0)
-					fullscreen = false
-				} else {
-					win.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
-					fullscreen = true
-				}
-			case sdl.K_q:
-				win.Destroy()
-				preswin.Destroy()
-				running = false
-			}
-		}
-		if running == false {
-			break
+	if !*lazy {
+		if err := pres.DecodeImages(); err != nil {
+			panic(err)
 		}
+	}
 
-		if dirty {
-			img, err := win.GetSurface()
-			if err != nil {
-				panic(err)
-			}
-			pres.Slides[index].Draw(img, img.Bounds())
-			win.UpdateSurface()
-
-			img, err = preswin.GetSurface()
-			if err != nil {
-				panic(err)
+	opts := present.Options{Title: filename, Serve: *serve, Token: *token, Web: *live}
+	if *onChange != "" {
+		opts.OnSlideChange = func(index int) {
+			cmd := exec.Command(*onChange, strconv.Itoa(index+1))
+			cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			if err := cmd.Start(); err != nil {
+				fmt.Fprintf(os.Stderr, "on-change: %v\n", err)
+				return
 			}
-			slab.DrawPresenter(img, img.Bounds(), pres, index)
-			preswin.UpdateSurface()
-			dirty = false
+			go cmd.Wait()
 		}
 	}
+	present.Present(pres, opts)
 }
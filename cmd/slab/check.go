@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/friedelschoen/slab"
+)
+
+/* check parses the presentation without showing it and reports every problem, exiting non-zero if any */
+func check(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	profiles := flags.String("profile", "", "comma-separated profiles enabling `%if`-blocks")
+	carry := flags.Bool("carry-config", false, "carry `%set`-options over into the next file")
+	width := flags.Int("width", 1024, "width to check the fit of text at")
+	height := flags.Int("height", 768, "height to check the fit of text at")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		usage()
+	}
+	/* options, colors, image-paths and overfull slides are reported while parsing */
+	pres := openPresentation(flags.Args(), *profiles, *carry, false, true)
+	problems := len(pres.Diagnostics)
+
+	for _, slide := range pres.Slides {
+		for _, cnt := range slide.Content {
+			img, ok := cnt.(*slab.ImageSlide)
+			if !ok {
+				continue
+			}
+			if err := img.Decode(); err != nil {
+				fmt.Fprintln(os.Stderr, slab.Diagnostic{Line: img.Line, Msg: fmt.Sprintf("image: %v", err)})
+				problems++
+			}
+		}
+	}
+	pres.FitUniform(*width, *height)
+	for i := range pres.Slides {
+		slide := &pres.Slides[i]
+		if !slide.Overfull && !slide.Fits(*width, *height) {
+			fmt.Fprintln(os.Stderr, slab.Diagnostic{Line: slide.Line, Msg: fmt.Sprintf("text of slide %d does not fit", i+1)})
+			problems++
+		}
+	}
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems found\n", problems)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/friedelschoen/slab"
+)
+
+/* exportGIF renders every slide into one frame of an animated GIF, shown for its `duration` */
+func exportGIF(args []string) {
+	flags := flag.NewFlagSet("gif", flag.ExitOnError)
+	width := flags.Int("width", 800, "width of the animation")
+	height := flags.Int("height", 600, "height of the animation")
+	delay := flags.Duration("delay", 5*time.Second, "time each slide is shown unless set by `duration`")
+	loops := flags.Int("loop", 0, "times the animation is played, 0 to loop forever")
+	output := flags.String("o", "slides.gif", "output file")
+	profiles := flags.String("profile", "", "comma-separated profiles enabling `%if`-blocks")
+	carry := flags.Bool("carry-config", false, "carry `%set`-options over into the next file")
+	strict := flags.Bool("strict", false, "fail on unknown or invalid options instead of warning")
+	lenient := flags.Bool("lenient", false, "leave out images that cannot be opened instead of failing")
+	flags.Parse(args)
+	if flags.NArg() < 1 || *width < 1 || *height < 1 || *loops < 0 {
+		usage()
+	}
+	pres := openPresentation(flags.Args(), *profiles, *carry, *strict, *lenient)
+	if err := pres.DecodeImages(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+		os.Exit(1)
+	}
+	pres.FitUniform(*width, *height)
+
+	/* an interrupt stops at the next line of text instead of finishing the animation */
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	anim := &gif.GIF{}
+	switch *loops {
+	case 0:
+		anim.LoopCount = 0 /* forever */
+	case 1:
+		anim.LoopCount = -1
+	default:
+		anim.LoopCount = *loops - 1
+	}
+	for i := range pres.Slides {
+		slide := &pres.Slides[i]
+		img, err := slab.RenderSlideContext(ctx, slide, *width, *height)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERR: slide %d: %v\n", i+1, err)
+			os.Exit(1)
+		}
+		frame := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, frame.Bounds(), img, image.Point{})
+
+		shown := *delay
+		if slide.Conf.Duration > 0 {
+			shown = slide.Conf.Duration
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, int(shown/(10*time.Millisecond)))
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	if err := gif.EncodeAll(file, anim); err != nil {
+		fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/friedelschoen/slab"
+)
+
+/* paper sizes in inches */
+var papers = map[string][2]float64{
+	"a4":     {8.27, 11.69},
+	"letter": {8.5, 11},
+}
+
+/* handout renders the slides as a grid of numbered thumbnails onto PNG-pages */
+func handout(args []string) {
+	flags := flag.NewFlagSet("handout", flag.ExitOnError)
+	perPage := flags.Int("per-page", 6, "slides per page")
+	paper := flags.String("paper", "a4", "paper size: a4 or letter")
+	dpi := flags.Int("dpi", 150, "resolution of the pages")
+	output := flags.String("o", "handout", "prefix of the output files")
+	profiles := flags.String("profile", "", "comma-separated profiles enabling `%if`-blocks")
+	carry := flags.Bool("carry-config", false, "carry `%set`-options over into the next file")
+	strict := flags.Bool("strict", false, "fail on unknown or invalid options instead of warning")
+	lenient := flags.Bool("lenient", false, "leave out images that cannot be opened instead of failing")
+	flags.Parse(args)
+	if flags.NArg() < 1 || *perPage < 1 {
+		usage()
+	}
+	size, ok := papers[*paper]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ERR: unknown paper size `%s`\n", *paper)
+		os.Exit(1)
+	}
+	pres := openPresentation(flags.Args(), *profiles, *carry, *strict, *lenient)
+	if err := pres.DecodeImages(); err != nil {
+		fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+		os.Exit(1)
+	}
+
+	/* an interrupt stops at the next line of text instead of finishing the page */
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pageR := image.Rect(0, 0, int(size[0]*float64(*dpi)), int(size[1]*float64(*dpi)))
+	margin := *dpi / 2
+	area := pageR.Inset(margin)
+
+	/* pages are portrait, so prefer more rows than columns */
+	cols := int(math.Ceil(math.Sqrt(float64(*perPage) / 2)))
+	rows := (*perPage + cols - 1) / cols
+	cw, ch := area.Dx()/cols, area.Dy()/rows
+	gap := *dpi / 8
+	labelH := ch / 10
+
+	labelcfg := pres.Conf
+	labelcfg.Foreground = image.Black
+	labelcfg.Padding = slab.Margins{}
+	labelcfg.FontSize = 0
+
+	border := image.NewUniform(color.Gray{150})
+	for start := 0; start < len(pres.Slides); start += *perPage {
+		page := image.NewRGBA(pageR)
+		draw.Draw(page, pageR, image.White, image.Point{}, draw.Src)
+		for i := start; i < min(start+*perPage, len(pres.Slides)); i++ {
+			n := i - start
+			cell := image.Rect(0, 0, cw, ch).Add(area.Min).Add(image.Pt(n%cols*cw, n/cols*ch)).Inset(gap)
+
+			/* slides are 4:3, centered in the cell above the label */
+			box := cell
+			box.Max.Y -= labelH
+			w, h := box.Dx(), box.Dy()
+			if w*3 > h*4 {
+				w = h * 4 / 3
+			} else {
+				h = w * 3 / 4
+			}
+			thumbR := image.Rect(0, 0, w, h).Add(box.Min).Add(image.Pt((box.Dx()-w)/2, (box.Dy()-h)/2))
+
+			draw.Draw(page, thumbR.Inset(-1), border, image.Point{}, draw.Src)
+			thumb, err := slab.RenderSlideContext(ctx, &pres.Slides[i], w, h)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERR: slide %d: %v\n", i+1, err)
+				os.Exit(1)
+			}
+			draw.Draw(page, thumbR, thumb, image.Point{}, draw.Src)
+
+			label := slab.MarkupText{{Text: strconv.Itoa(i + 1)}}
+			label.Draw(page, image.Rect(thumbR.Min.X, thumbR.Max.Y, thumbR.Max.X, thumbR.Max.Y+labelH), labelcfg)
+		}
+
+		filename := fmt.Sprintf("%s-%d.png", *output, start / *perPage + 1)
+		file, err := os.Create(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+			os.Exit(1)
+		}
+		if err := png.Encode(file, page); err != nil {
+			fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+			os.Exit(1)
+		}
+		file.Close()
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/friedelschoen/slab"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: slab <command> [options] <file>...\n\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	fmt.Fprintf(os.Stderr, "  notes    print the speaker notes of every slide as Markdown\n")
+	fmt.Fprintf(os.Stderr, "  handout  render pages with several slides each to PNG\n")
+	fmt.Fprintf(os.Stderr, "  check    report problems in the presentation without showing it\n")
+	fmt.Fprintf(os.Stderr, "  gif      render the slides into an animated GIF\n")
+	os.Exit(1)
+}
+
+/* openPresentation parses `filenames` in sequence, `carry` carries `%set`-options over into the next file */
+func openPresentation(filenames []string, profiles string, carry, strict, lenient bool) *slab.Presentation {
+	var enabled []string
+	if profiles != "" {
+		enabled = strings.Split(profiles, ",")
+	}
+	pres, err := slab.ParseFiles(filenames, carry, strict, lenient, enabled...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
+		os.Exit(1)
+	}
+	return pres
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "notes":
+		notes(os.Args[2:])
+	case "handout":
+		handout(os.Args[2:])
+	case "check":
+		check(os.Args[2:])
+	case "gif":
+		exportGIF(os.Args[2:])
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+)
+
+/* notes writes a Markdown-section per slide containing its notes */
+func notes(args []string) {
+	flags := flag.NewFlagSet("notes", flag.ExitOnError)
+	profiles := flags.String("profile", "", "comma-separated profiles enabling `%if`-blocks")
+	carry := flags.Bool("carry-config", false, "carry `%set`-options over into the next file")
+	strict := flags.Bool("strict", false, "fail on unknown or invalid options instead of warning")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		usage()
+	}
+	pres := openPresentation(flags.Args(), *profiles, *carry, *strict, true) /* images are not drawn */
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for i, slide := range pres.Slides {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if title := slide.Title(); title != "" {
+			fmt.Fprintf(out, "## Slide %d: %s\n", i+1, title)
+		} else {
+			fmt.Fprintf(out, "## Slide %d\n", i+1)
+		}
+		if slide.Notes != "" {
+			fmt.Fprintf(out, "\n%s\n", slide.Notes)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package slab
 
 import (
 	"errors"
+	"image"
 	"image/color"
+	"math"
+	"sync"
 
 	"golang.org/x/image/colornames"
 )
@@ -113,3 +116,94 @@ func hexNibble(c byte) (uint8, bool) {
 		return 0, false
 	}
 }
+
+/* transparent reports whether `c` is fully transparent */
+func transparent(c color.Color) bool {
+	_, _, _, a := c.RGBA()
+	return a == 0
+}
+
+/* luminance returns the relative luminance of `c` as defined by WCAG */
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	linear := func(v uint32) float64 {
+		f := float64(v) / 0xffff
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+/* averageColor returns the mean color of `img`, sampling at most ~64×64 pixels */
+func averageColor(img image.Image) color.Color {
+	if u, ok := img.(*image.Uniform); ok {
+		return u.C
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return color.Black
+	}
+	stepX, stepY := max(b.Dx()/64, 1), max(b.Dy()/64, 1)
+	var r, g, bl, n uint64
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			bl += uint64(cb)
+			n++
+		}
+	}
+	return color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), 0xffff}
+}
+
+/* contrastColor returns either black or white, whichever contrasts most with `bg`.
+ * Only a uniform `bg` is decided right away, images are decoded when first drawn. */
+func contrastColor(bg image.Image) image.Image {
+	if _, ok := bg.(*image.Uniform); !ok {
+		return &contrastFill{bg: bg}
+	}
+	return contrastUniform(bg)
+}
+
+/* contrastUniform returns either black or white, whichever contrasts most with the average of `bg` */
+func contrastUniform(bg image.Image) *image.Uniform {
+	l := luminance(averageColor(bg))
+	/* contrast ratios (L1 + 0.05) / (L2 + 0.05) against white and black */
+	if 1.05/(l+0.05) > (l+0.05)/0.05 {
+		return image.White
+	}
+	return image.Black
+}
+
+/* contrastFill is the contrastColor of an image, averaged on first use */
+type contrastFill struct {
+	bg   image.Image
+	once sync.Once
+	c    *image.Uniform
+}
+
+func (f *contrastFill) uniform() *image.Uniform {
+	f.once.Do(func() {
+		f.c = contrastUniform(f.bg)
+	})
+	return f.c
+}
+
+func (f *contrastFill) ColorModel() color.Model {
+	return f.uniform().ColorModel()
+}
+
+func (f *contrastFill) Bounds() image.Rectangle {
+	return f.uniform().Bounds()
+}
+
+func (f *contrastFill) At(x, y int) color.Color {
+	return f.uniform().At(x, y)
+}
+
+func (f *contrastFill) span(image.Rectangle) image.Image {
+	return f.uniform()
+}
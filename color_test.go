@@ -0,0 +1,41 @@
+package slab
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContrastColorLazy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bg.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bg := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range bg.Pix {
+		bg.Pix[i] = 0xff
+	}
+	if err := png.Encode(file, bg); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	slide, err := NewImageSlide(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fg := contrastColor(&coverFill{img: slide})
+	if slide.src != nil {
+		t.Error("background is decoded before drawing")
+	}
+	if got := fg.(spanner).span(image.Rect(0, 0, 8, 8)); got.At(0, 0) != color.Black {
+		t.Errorf("foreground on white is %v, want black", got.At(0, 0))
+	}
+	if got := contrastColor(image.Black); got != image.White {
+		t.Errorf("foreground on black is %v, want white", got)
+	}
+}
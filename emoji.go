@@ -0,0 +1,73 @@
+package slab
+
+import "strings"
+
+/* Emoji maps shortcodes (without colons) to their emoji, hosts may add or replace entries */
+var Emoji = map[string]string{
+	"smile":            "😄",
+	"grin":             "😁",
+	"joy":              "😂",
+	"wink":             "😉",
+	"blush":            "😊",
+	"heart_eyes":       "😍",
+	"thinking":         "🤔",
+	"neutral_face":     "😐",
+	"cry":              "😢",
+	"scream":           "😱",
+	"sunglasses":       "😎",
+	"tada":             "🎉",
+	"rocket":           "🚀",
+	"fire":             "🔥",
+	"star":             "⭐",
+	"sparkles":         "✨",
+	"heart":            "❤️",
+	"thumbsup":         "👍",
+	"+1":               "👍",
+	"thumbsdown":       "👎",
+	"-1":               "👎",
+	"clap":             "👏",
+	"wave":             "👋",
+	"pray":             "🙏",
+	"eyes":             "👀",
+	"bulb":             "💡",
+	"warning":          "⚠️",
+	"x":                "❌",
+	"white_check_mark": "✅",
+	"heavy_check_mark": "✔️",
+	"question":         "❓",
+	"exclamation":      "❗",
+	"zap":              "⚡",
+	"bug":              "🐛",
+	"wrench":           "🔧",
+	"hammer":           "🔨",
+	"lock":             "🔒",
+	"key":              "🔑",
+	"memo":             "📝",
+	"book":             "📖",
+	"chart":            "📈",
+	"calendar":         "📅",
+	"clock":            "🕒",
+	"hourglass":        "⌛",
+	"computer":         "💻",
+	"coffee":           "☕",
+	"point_right":      "👉",
+	"arrow_right":      "➡️",
+	"arrow_left":       "⬅️",
+	"100":              "💯",
+}
+
+/* emojiPrefix expands a `:shortcode:` at the start of `content`, returning the emoji and the length of the shortcode */
+func emojiPrefix(content string) (string, int, bool) {
+	if !strings.HasPrefix(content, ":") {
+		return "", 0, false
+	}
+	name, _, found := strings.Cut(content[1:], ":")
+	if !found || name == "" || strings.ContainsAny(name, " \t\n") {
+		return "", 0, false
+	}
+	emoji, ok := Emoji[name]
+	if !ok {
+		return "", 0, false
+	}
+	return emoji, len(name) + 2, true
+}
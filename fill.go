@@ -0,0 +1,173 @@
+package slab
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+/* spanner is a text-fill which adapts to the text-box it is drawn in */
+type spanner interface {
+	span(r image.Rectangle) image.Image
+}
+
+/* everywhere are the bounds of fills which extend past their text-box, glyphs may overhang it */
+var everywhere = image.Rect(math.MinInt32/2, math.MinInt32/2, math.MaxInt32/2, math.MaxInt32/2)
+
+type GradientDirection int
+
+const (
+	GradientHorizontal GradientDirection = iota
+	GradientVertical
+	GradientDiagonal
+)
+
+/* Gradient is a linear gradient from From to To, spanned over the text-box when drawn */
+type Gradient struct {
+	From, To  color.Color
+	Direction GradientDirection
+	r         image.Rectangle
+}
+
+func (g *Gradient) span(r image.Rectangle) image.Image {
+	spanned := *g
+	spanned.r = r
+	return &spanned
+}
+
+func (g *Gradient) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (g *Gradient) Bounds() image.Rectangle {
+	return everywhere
+}
+
+func (g *Gradient) At(x, y int) color.Color {
+	var t float64
+	switch g.Direction {
+	case GradientVertical:
+		t = float64(y-g.r.Min.Y) / float64(max(g.r.Dy(), 1))
+	case GradientDiagonal:
+		t = float64(x-g.r.Min.X+y-g.r.Min.Y) / float64(max(g.r.Dx()+g.r.Dy(), 1))
+	default:
+		t = float64(x-g.r.Min.X) / float64(max(g.r.Dx(), 1))
+	}
+	t = min(max(t, 0), 1)
+
+	r0, g0, b0, a0 := g.From.RGBA()
+	r1, g1, b1, a1 := g.To.RGBA()
+	lerp := func(a, b uint32) uint16 {
+		return uint16(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return color.RGBA64{lerp(r0, r1), lerp(g0, g1), lerp(b0, b1), lerp(a0, a1)}
+}
+
+/* textureFill stretches an image over the text-box */
+type textureFill struct {
+	image.Image
+}
+
+func (t *textureFill) span(r image.Rectangle) image.Image {
+	if r.Empty() {
+		return image.Transparent
+	}
+	scaled := image.NewRGBA(r)
+	xdraw.ApproxBiLinear.Scale(scaled, r, t.Image, t.Image.Bounds(), xdraw.Src, nil)
+	return &clampedImage{scaled}
+}
+
+/* coverFill scales an image to cover the slide, keeping the last scaled copy */
+type coverFill struct {
+	img *ImageSlide
+
+	mu     sync.Mutex
+	r      image.Rectangle
+	scaled *image.RGBA
+}
+
+/* source returns the decoded image, black if it fails to decode */
+func (c *coverFill) source() image.Image {
+	if err := c.img.Decode(); err != nil {
+		warnOnce("background: %v", err)
+		return image.Black
+	}
+	return c.img.src
+}
+
+func (c *coverFill) ColorModel() color.Model {
+	return c.source().ColorModel()
+}
+
+func (c *coverFill) Bounds() image.Rectangle {
+	return c.source().Bounds()
+}
+
+func (c *coverFill) At(x, y int) color.Color {
+	return c.source().At(x, y)
+}
+
+func (c *coverFill) span(r image.Rectangle) image.Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scaled == nil || c.r != r {
+		src := c.source()
+		c.r = r
+		c.scaled = image.NewRGBA(r)
+		srcr := cropImage(src.Bounds(), r, Center, Middle)
+		xdraw.ApproxBiLinear.Scale(c.scaled, r, src, srcr, xdraw.Src, nil)
+	}
+	return c.scaled
+}
+
+/* clampedImage extends the edges of its image infinitely */
+type clampedImage struct {
+	*image.RGBA
+}
+
+func (c *clampedImage) Bounds() image.Rectangle {
+	return everywhere
+}
+
+func (c *clampedImage) At(x, y int) color.Color {
+	r := c.RGBA.Bounds()
+	return c.RGBA.At(min(max(x, r.Min.X), r.Max.X-1), min(max(y, r.Min.Y), r.Max.Y-1))
+}
+
+/* fade returns `img` with its alpha multiplied by `opacity`, nil stays nil */
+func fade(img image.Image, opacity float64) image.Image {
+	switch img := img.(type) {
+	case nil:
+		return nil
+	case *image.Uniform:
+		return image.NewUniform(fadeColor(img.C, opacity))
+	default:
+		return &fadedImage{img, opacity}
+	}
+}
+
+/* fadeColor multiplies the alpha of `c` by `opacity` */
+func fadeColor(c color.Color, opacity float64) color.Color {
+	r, g, b, a := c.RGBA()
+	scale := func(v uint32) uint16 {
+		return uint16(float64(v) * opacity)
+	}
+	return color.RGBA64{scale(r), scale(g), scale(b), scale(a)}
+}
+
+/* fadedImage is an image with its alpha multiplied by `opacity` */
+type fadedImage struct {
+	image.Image
+	opacity float64
+}
+
+func (f *fadedImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+func (f *fadedImage) At(x, y int) color.Color {
+	return fadeColor(f.Image.At(x, y), f.opacity)
+}
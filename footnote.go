@@ -0,0 +1,72 @@
+package slab
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/* isFootnoteDef reports whether `line` defines a footnote, `[^label]:` with a label without whitespace or `]` */
+func isFootnoteDef(line string) bool {
+	rest, ok := strings.CutPrefix(line, "[^")
+	if !ok {
+		return false
+	}
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return r == ']' || unicode.IsSpace(r)
+	})
+	return end > 0 && strings.HasPrefix(rest[end:], "]:")
+}
+
+/* resolveFootnotes numbers the footnote-references in `content` by first appearance and returns the referenced texts */
+func resolveFootnotes(content []SlideContent, defs map[string]string) []string {
+	numbers := make(map[string]int)
+	var notes []string
+	for _, cnt := range content {
+		text, ok := cnt.(MarkupText)
+		if !ok {
+			continue
+		}
+		for i, part := range text {
+			if part.Attr&Footnote == 0 {
+				continue
+			}
+			n, ok := numbers[part.Text]
+			if !ok {
+				def, ok := defs[part.Text]
+				if !ok {
+					fmt.Fprintf(os.Stderr, "undefined footnote `%s`\n", part.Text)
+					text[i].Text = "[" + part.Text + "?]"
+					continue
+				}
+				notes = append(notes, def)
+				n = len(notes)
+				numbers[part.Text] = n
+			}
+			text[i].Text = strconv.Itoa(n)
+		}
+	}
+	return notes
+}
+
+/* drawFootnotes draws the numbered `notes` into the band `bounds` */
+func drawFootnotes(img draw.Image, bounds image.Rectangle, notes []string, cfg PresConfig) {
+	var text MarkupText
+	for i, note := range notes {
+		if i > 0 {
+			text = append(text, Markup{Text: "\n"})
+		}
+		text = append(text, Markup{Attr: Footnote, Text: strconv.Itoa(i + 1)}, Markup{Text: " " + note})
+	}
+	cfg.Align = Left
+	cfg.VAlign = Bottom
+	cfg.FontSize = 0
+	cfg.MinFontSize, cfg.MaxFontSize = 0, 0
+	cfg.Padding = Margins{}
+	cfg.NewlineSpacing = 0
+	text.Draw(img, bounds, cfg)
+}
@@ -0,0 +1,27 @@
+package slab
+
+import "testing"
+
+func TestIsFootnoteDef(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"[^1]: text", true},
+		{"[^note]:text", true},
+		{"[^a-b_c]:", true},
+		{"[^]: empty label", false},
+		{"[^two words]: text", false},
+		{"[^1] text with a colon]: here", false},
+		{"[^1][^2]: text", false},
+		{"see [^1]: not at the start", false},
+		{" [^1]: indented", false},
+		{"[^1]", false},
+		{"[1]: text", false},
+	}
+	for _, tt := range tests {
+		if got := isFootnoteDef(tt.line); got != tt.want {
+			t.Errorf("isFootnoteDef(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package slab
+
+import (
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+/* faceKey identifies a face by its font, size, dpi and hinting */
+type faceKey struct {
+	font      *opentype.Font
+	size, dpi float64
+	hinting   font.Hinting
+}
+
+/* glyphKey identifies the advance of `r` (next is -1) or the kerning of `r` and `next` */
+type glyphKey struct {
+	faceKey
+	r, next rune
+}
+
+/* glyphCache memoizes advances, kerning-pairs and metrics across layouts */
+var glyphCache = struct {
+	sync.Mutex
+	glyphs  map[glyphKey]fixed.Int26_6
+	metrics map[faceKey]font.Metrics
+}{glyphs: make(map[glyphKey]fixed.Int26_6), metrics: make(map[faceKey]font.Metrics)}
+
+/* cachedFace measures glyphs using glyphCache, the face is only created on a cache-miss */
+type cachedFace struct {
+	key  faceKey
+	face font.Face
+}
+
+func (a MarkupAttribute) cachedFace(size float64, cfg PresConfig) *cachedFace {
+	return &cachedFace{key: faceKey{a.font(cfg), a.faceSize(size, cfg), cfg.DPI, cfg.Hinting}}
+}
+
+func (c *cachedFace) open() font.Face {
+	if c.face == nil {
+		c.face, _ = opentype.NewFace(c.key.font, &opentype.FaceOptions{DPI: c.key.dpi, Size: c.key.size, Hinting: c.key.hinting})
+	}
+	return c.face
+}
+
+func (c *cachedFace) lookup(r, next rune) fixed.Int26_6 {
+	key := glyphKey{c.key, r, next}
+	glyphCache.Lock()
+	v, ok := glyphCache.glyphs[key]
+	glyphCache.Unlock()
+	if ok {
+		return v
+	}
+
+	if next == -1 {
+		v, _ = c.open().GlyphAdvance(r)
+	} else {
+		v = c.open().Kern(r, next)
+	}
+
+	glyphCache.Lock()
+	if len(glyphCache.glyphs) >= 1<<16 {
+		/* findSize tries many sizes, start over instead of growing without bound */
+		clear(glyphCache.glyphs)
+		clear(glyphCache.metrics)
+	}
+	glyphCache.glyphs[key] = v
+	glyphCache.Unlock()
+	return v
+}
+
+func (c *cachedFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return c.lookup(r, -1), true
+}
+
+func (c *cachedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return c.lookup(r0, r1)
+}
+
+func (c *cachedFace) Metrics() font.Metrics {
+	glyphCache.Lock()
+	met, ok := glyphCache.metrics[c.key]
+	glyphCache.Unlock()
+	if ok {
+		return met
+	}
+	met = c.open().Metrics()
+	glyphCache.Lock()
+	glyphCache.metrics[c.key] = met
+	glyphCache.Unlock()
+	return met
+}
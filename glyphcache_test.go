@@ -0,0 +1,46 @@
+package slab
+
+import (
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestGlyphCacheKeyedByHinting(t *testing.T) {
+	const size = 41.75 /* a size no other test measures at, its cached advance is faked */
+	cfg := defaultConf()
+	cfg.Hinting = font.HintingNone
+	var plain MarkupAttribute
+	fake := glyphKey{plain.cachedFace(size, cfg).key, 'x', -1}
+	glyphCache.Lock()
+	glyphCache.glyphs[fake] = fixed.I(1000)
+	glyphCache.Unlock()
+	t.Cleanup(func() {
+		glyphCache.Lock()
+		delete(glyphCache.glyphs, fake)
+		glyphCache.Unlock()
+	})
+
+	if adv := plain.measureText("x", 0, size, cfg); adv != fixed.I(1000) {
+		t.Fatalf("cached advance is not used, got %v", adv)
+	}
+	for _, hinting := range []font.Hinting{font.HintingVertical, font.HintingFull} {
+		cfg.Hinting = hinting
+		if adv := plain.measureText("x", 0, size, cfg); adv == fixed.I(1000) {
+			t.Errorf("hinting %v measures the advance cached without hinting", hinting)
+		}
+	}
+}
+
+func BenchmarkMeasureText(b *testing.B) {
+	cfg := defaultConf()
+	text := "The quick brown fox jumps over the lazy dog"
+	var plain MarkupAttribute
+	b.ReportAllocs()
+	for b.Loop() {
+		for size := 10.0; size < 60; size += 5 {
+			plain.measureText(text, 0, size, cfg)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package slab
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+/* DrawHelp draws `help`, a markup-text, on a semi-transparent panel over `bounds` */
+func DrawHelp(img draw.Image, bounds image.Rectangle, pres *Presentation, help string) {
+	panel := bounds.Inset(min(bounds.Dx(), bounds.Dy()) / 12)
+	draw.Draw(img, panel, image.NewUniform(color.RGBA{0, 0, 0, 220}), image.Point{}, draw.Over)
+
+	cfg := pres.Conf
+	cfg.Foreground = image.NewUniform(color.Gray{230})
+	cfg.Outline = nil
+	cfg.AttrColors = nil
+	cfg.Padding = uniformMargins(Length{Value: 0.05})
+	cfg.Align = Left
+	cfg.VAlign = Middle
+	cfg.FontSize = 0
+	cfg.MinFontSize, cfg.MaxFontSize = 0, 0
+	cfg.DropCap = 0
+	cfg.TabStops = true
+	cfg.TabSize = 48
+
+	var text MarkupBuilder
+	text.Feed(help)
+	text.Text().Draw(img, panel, cfg)
+}
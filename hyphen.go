@@ -0,0 +1,125 @@
+package slab
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/math/fixed"
+)
+
+/* enPatterns is a small set of Liang-patterns for English, odd values allow a break, even values inhibit it */
+const enPatterns = `
+.ab1 .ac1 .ad1 .al1 .an1 .com1 .con1 .de1 .dis1 .em1 .en1 .ex1 .for1 .im1 .in1 .inter1
+.mis1 .non1 .over1 .per1 .pre1 .pro1 .re1 .sub1 .super1 .trans1 .un1
+1tion 1sion 1tial 1cial 1ment 1ness 1less 1ful 1ture 1ity 1ize 1ise 1able 1ible 1ism 1ist
+hy3ph he2n hena4 hen5at n2at 1tio 4ted. 4ded. 2ed. 2es. 2le.
+b1b c1c d1d f1f g1g l1l m1m n1n p1p r1r s1s t1t z1z
+b1d b1j b1m b1s b1t c1t d1m d1n d1v g1m g1n l1b l1c l1d l1f l1g l1k l1m l1p l1s l1t l1v
+m1b m1n m1p m1s n1c n1d n1f n1g n1j n1k n1q n1s n1t n1v n1z p1t r1b r1c r1d r1f r1g r1k
+r1l r1m r1n r1p r1s r1t r1v r1w s1c s1k s1m s1p s1t ns2t rs2t x1c x1p x1t ck1
+1ba 1be 1bi 1bo 1bu 1ca 1ce 1ci 1co 1cu 1da 1de 1di 1do 1du 1fa 1fe 1fi 1fo 1fu
+1ga 1ge 1gi 1go 1gu 1ja 1je 1jo 1ju 1ka 1ke 1ki 1ko 1la 1le 1li 1lo 1lu 1ma 1me 1mi 1mo 1mu
+1na 1ne 1ni 1no 1nu 1pa 1pe 1pi 1po 1pu 1ra 1re 1ri 1ro 1ru 1sa 1se 1si 1so 1su
+1ta 1te 1ti 1to 1tu 1va 1ve 1vi 1vo 1vu 1za 1ze 1zi 1zo
+1bl 1br 1cl 1cr 1dr 1fl 1fr 1gl 1gr 1pl 1pr 1tr 1str
+b2l b2r c2l c2r d2r f2l f2r g2l g2r p2l p2r t2r s2t2r
+c2h g2h p2h s2h t2h w2h c2k q2u
+a2i a2u e2a e2e e2i o2a o2o o2u i2e u2e 2io o2n
+`
+
+/* hyphenator finds the syllable-boundaries of words using Liang's algorithm */
+type hyphenator struct {
+	patterns map[string][]byte /* letters of a pattern to the values around them */
+	maxLen   int               /* longest pattern in runes */
+}
+
+/* hyphenators are the patterns by language, as accepted by `hyphenate` */
+var hyphenators = map[string]func() *hyphenator{
+	"en": sync.OnceValue(func() *hyphenator { return newHyphenator(enPatterns) }),
+}
+
+func newHyphenator(patterns string) *hyphenator {
+	h := &hyphenator{patterns: make(map[string][]byte)}
+	for _, pat := range strings.Fields(patterns) {
+		var letters []rune
+		values := []byte{0}
+		for _, r := range pat {
+			if r >= '0' && r <= '9' {
+				values[len(values)-1] = byte(r - '0')
+				continue
+			}
+			letters = append(letters, r)
+			values = append(values, 0)
+		}
+		h.patterns[string(letters)] = values
+		h.maxLen = max(h.maxLen, len(letters))
+	}
+	return h
+}
+
+/* points returns the byte-offsets in `word` where it may be hyphenated, in ascending order.
+ * Only runs of at least five letters are split, leaving two letters before and three after a break. */
+func (h *hyphenator) points(word string) []int {
+	var points []int
+	var run []rune
+	var offsets []int
+	flush := func() {
+		if len(run) >= 5 {
+			w := append(append([]rune{'.'}, run...), '.')
+			values := make([]byte, len(w)+1)
+			for i := range w {
+				for j := i + 1; j <= min(i+h.maxLen, len(w)); j++ {
+					pat, ok := h.patterns[string(w[i:j])]
+					if !ok {
+						continue
+					}
+					for k, v := range pat {
+						values[i+k] = max(values[i+k], v)
+					}
+				}
+			}
+			/* values[k+1] lies between run[k-1] and run[k], never split off a single letter */
+			last := 0
+			for k := 2; k <= len(run)-3; k++ {
+				if values[k+1]%2 == 1 && k-last >= 2 {
+					points = append(points, offsets[k])
+					last = k
+				}
+			}
+		}
+		run, offsets = run[:0], offsets[:0]
+	}
+	for i, r := range word {
+		if !unicode.IsLetter(r) {
+			flush()
+			continue
+		}
+		run = append(run, unicode.ToLower(r))
+		offsets = append(offsets, i)
+	}
+	flush()
+	return points
+}
+
+/* hyphenate splits `word` at the last syllable-boundary whose head, followed by a hyphen, still fits
+ * within `limit` px when starting at `start`. The head includes the hyphen. */
+func (c PresConfig) hyphenate(a MarkupAttribute, word string, start fixed.Int26_6, limit int, size float64) (head, tail string, adv fixed.Int26_6, ok bool) {
+	hyph, found := hyphenators[c.Hyphenate]
+	if !found || a&(Code|BigText|NoWrap|Footnote|Math) != 0 {
+		return "", "", 0, false
+	}
+	if r, _ := utf8.DecodeRuneInString(word); unicode.IsSpace(r) {
+		return "", "", 0, false
+	}
+	points := hyph().points(word)
+	for i := len(points) - 1; i >= 0; i-- {
+		head = word[:points[i]] + "-"
+		adv = a.measureText(head, start, size, c)
+		if (start + adv).Ceil() <= limit {
+			return head, word[points[i]:], adv, true
+		}
+	}
+	return "", "", 0, false
+}
@@ -1,12 +1,16 @@
 package slab
 
 import (
+	"context"
+	"fmt"
 	"image"
 	"image/draw"
 	"iter"
 	"math"
+	"os"
 	"slices"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 
@@ -25,6 +29,10 @@ const (
 	Code
 	BigText
 	NoWrap
+	Footnote  /* footnote-reference, rendered as superscript */
+	Smallcaps /* lowercase letters drawn as reduced capitals */
+	Hidden    /* laid out but not drawn, a fragment not revealed yet */
+	Math      /* TeX-subset math-expression, Text holds its source */
 )
 
 type Markup struct {
@@ -43,13 +51,40 @@ type MarkupText []Markup
 //   - Italic:         *text* or _text_
 //   - Underline:      __text__
 //   - Strikethrough:  ~~text~~
-//   - No Wrap:  	   @text@
+//   - No Wrap:  	   @text@ (never wrapped, but breaks at every newline)
+//   - Footnote:       [^label]
+//   - Small Caps:     ^^text^^
+//   - Emoji:          :shortcode: (see Emoji)
+//   - Math:           $$\frac{a}{b}$$, or $x^2$ with InlineMath (see parseMath)
+//
+// Lines fed by FeedLine are joined according to a LineJoin: LineJoinSpace
+// collapses the whitespace around the join to a single space, LineJoinBreak
+// keeps every line on its own and turns blank lines into an empty line.
+// Blank lines, fed as "\n", always end a paragraph. LineBreak starts a new
+// line within the paragraph.
 type MarkupBuilder struct {
-	out   MarkupText
-	buf   []rune
-	state MarkupAttribute
+	out     MarkupText
+	buf     []rune
+	state   MarkupAttribute
+	lineEnd bool /* the content ends in a line fed by FeedLine */
+	broken  bool /* the content ends in a LineBreak */
+
+	mathErr error /* the first invalid math-expression since the last MathErr */
+
+	KeepLeading bool /* keep newlines fed before any text instead of dropping them */
+	InlineMath  bool /* treat `$...$` as math, `$$...$$` always is */
 }
 
+/* lineBreak ends a visual line without the paragraph-gap of a newline */
+const lineBreak = '\u2028'
+
+type LineJoin int
+
+const (
+	LineJoinSpace LineJoin = iota /* consecutive lines form one paragraph */
+	LineJoinBreak                 /* every line starts a new line */
+)
+
 func (b *MarkupBuilder) flush() {
 	if len(b.buf) == 0 {
 		return
@@ -61,8 +96,40 @@ func (b *MarkupBuilder) flush() {
 	b.buf = b.buf[:0]
 }
 
-func (b *MarkupBuilder) Feed(content string) {
+/* FeedLine feeds a line of text, joined to a directly preceding line according to `join` */
+func (b *MarkupBuilder) FeedLine(line string, join LineJoin) {
+	line = strings.TrimRightFunc(line, unicode.IsSpace)
+	switch {
+	case join == LineJoinSpace && b.lineEnd:
+		line = " " + strings.TrimLeftFunc(line, unicode.IsSpace)
+	case join == LineJoinBreak && b.Dirty() && !b.broken:
+		/* after a blank line this leaves an empty line between paragraphs */
+		line = "\n" + line
+	}
+	b.Feed(line)
+	b.lineEnd = true
+}
+
+/* BreakLine ends the line fed last by FeedLine, the next line is not joined to it */
+func (b *MarkupBuilder) BreakLine() {
+	if b.lineEnd {
+		b.Feed("\n")
+	}
+}
+
+/* LineBreak starts a new line without ending the paragraph, the attributes carry over */
+func (b *MarkupBuilder) LineBreak() {
 	if !b.Dirty() {
+		return
+	}
+	b.Feed(string(lineBreak))
+	b.broken = true
+}
+
+func (b *MarkupBuilder) Feed(content string) {
+	b.lineEnd = false
+	b.broken = false
+	if !b.Dirty() && !b.KeepLeading {
 		content = strings.TrimLeft(content, "\n")
 	}
 	for len(content) > 0 {
@@ -80,12 +147,18 @@ func (b *MarkupBuilder) Feed(content string) {
 		case b.state&Code == 0 && strings.HasPrefix(content, "\\=="):
 			b.buf = append(b.buf, '=', '=')
 			content = content[3:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "\\^^"):
+			b.buf = append(b.buf, '^', '^')
+			content = content[3:]
 		case b.state&Code == 0 && strings.HasPrefix(content, "\\*"):
 			b.buf = append(b.buf, '*')
 			content = content[2:]
 		case b.state&Code == 0 && strings.HasPrefix(content, "\\_"):
 			b.buf = append(b.buf, '_')
 			content = content[2:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "\\[^"):
+			b.buf = append(b.buf, '[', '^')
+			content = content[3:]
 		case b.state&Code == 0 && strings.HasPrefix(content, "\\@"):
 			b.buf = append(b.buf, '@')
 			content = content[2:]
@@ -107,6 +180,29 @@ func (b *MarkupBuilder) Feed(content string) {
 			b.flush()
 			b.state ^= Strikethrough
 			content = content[2:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "\\$"):
+			b.buf = append(b.buf, '$')
+			content = content[2:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "$"):
+			src, n, ok := cutMath(content)
+			if !ok || !b.InlineMath && !strings.HasPrefix(content, "$$") {
+				b.buf = append(b.buf, '$')
+				content = content[1:]
+				break
+			}
+			if _, err := cachedMath(src); err != nil && b.mathErr == nil {
+				b.mathErr = fmt.Errorf("math `%s`: %w, set verbatim", src, err)
+			}
+			b.flush()
+			b.out = append(b.out, Markup{
+				Attr: b.state | Math,
+				Text: src,
+			})
+			content = content[n:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "^^"):
+			b.flush()
+			b.state ^= Smallcaps
+			content = content[2:]
 		case b.state&Code == 0 && strings.HasPrefix(content, "=="):
 			b.flush()
 			b.state ^= BigText
@@ -127,6 +223,22 @@ func (b *MarkupBuilder) Feed(content string) {
 			b.flush()
 			b.state ^= Code
 			content = content[1:]
+		case b.state&Code == 0 && strings.HasPrefix(content, "[^") && strings.Contains(content, "]"):
+			label, rest, _ := strings.Cut(content[2:], "]")
+			b.flush()
+			b.out = append(b.out, Markup{
+				Attr: b.state | Footnote,
+				Text: label,
+			})
+			content = rest
+		case b.state&Code == 0 && strings.HasPrefix(content, ":"):
+			if emoji, n, ok := emojiPrefix(content); ok {
+				b.buf = append(b.buf, []rune(emoji)...)
+				content = content[n:]
+			} else {
+				b.buf = append(b.buf, ':')
+				content = content[1:]
+			}
 		default:
 			chr, sz := utf8.DecodeRuneInString(content)
 			b.buf = append(b.buf, chr)
@@ -136,6 +248,33 @@ func (b *MarkupBuilder) Feed(content string) {
 	b.flush()
 }
 
+/* cutMath returns the source of the `$$`- or `$`-delimited math at the start of `content` and its length with delimiters.
+ * Like in pandoc, `$` is only math if followed by a non-space and closed by a `$` after a non-space not followed by a digit,
+ * so prices stay text. */
+func cutMath(content string) (src string, n int, ok bool) {
+	if strings.HasPrefix(content, "$$") {
+		src, _, ok = strings.Cut(content[2:], "$$")
+		if !ok || strings.TrimSpace(src) == "" {
+			return "", 0, false
+		}
+		return strings.TrimSpace(src), len(src) + 4, true
+	}
+	if len(content) < 3 || content[1] == ' ' || content[1] == '\t' || content[1] == '$' {
+		return "", 0, false
+	}
+	for i := 2; i < len(content); i++ {
+		switch {
+		case content[i-1] == '\\':
+			/* escaped, like `\$` */
+		case content[i] != '$' || content[i-1] == ' ' || content[i-1] == '\t':
+		case i+1 < len(content) && content[i+1] >= '0' && content[i+1] <= '9':
+		default:
+			return content[1:i], i + 1, true
+		}
+	}
+	return "", 0, false
+}
+
 func (b *MarkupBuilder) Text() MarkupText {
 	b.flush() /* flush all contents */
 
@@ -146,10 +285,20 @@ func (b *MarkupBuilder) Dirty() bool {
 	return len(b.out) != 0 || len(b.buf) != 0 || b.state != 0
 }
 
+/* MathErr returns the first invalid math-expression fed since the last call, if any, and forgets it */
+func (b *MarkupBuilder) MathErr() error {
+	err := b.mathErr
+	b.mathErr = nil
+	return err
+}
+
 func (b *MarkupBuilder) Reset() {
 	b.out = nil
 	b.buf = nil
 	b.state = 0
+	b.lineEnd = false
+	b.broken = false
+	b.KeepLeading = false
 }
 
 func (a MarkupAttribute) has(has MarkupAttribute) bool {
@@ -198,129 +347,304 @@ func (a MarkupAttribute) font(cfg PresConfig) *opentype.Font {
 	}
 }
 
-func (a MarkupAttribute) face(size float64, cfg PresConfig) font.Face {
-	font := a.font(cfg)
+/* sameFace reports whether `a` and `b` are rendered using the same face */
+func (a MarkupAttribute) sameFace(b MarkupAttribute, cfg PresConfig) bool {
+	return a.font(cfg) == b.font(cfg) && (a^b)&(BigText|Footnote) == 0
+}
 
+/* faceSize returns the size of the face for `a` in text of `size` */
+func (a MarkupAttribute) faceSize(size float64, cfg PresConfig) float64 {
 	if a.has(BigText) {
 		size *= cfg.BigText
 	}
-	face, _ := opentype.NewFace(font, &opentype.FaceOptions{DPI: 72, Size: size})
+	if a.has(Footnote) {
+		size *= 0.6
+	}
+	return size
+}
+
+func (a MarkupAttribute) face(size float64, cfg PresConfig) font.Face {
+	face, _ := opentype.NewFace(a.font(cfg), &opentype.FaceOptions{DPI: cfg.DPI, Size: a.faceSize(size, cfg), Hinting: cfg.Hinting})
 	return face
 }
 
+/* smallFace returns the face for reduced capitals, `face` itself if `a` is not Smallcaps */
+func (a MarkupAttribute) smallFace(face font.Face, size float64, cfg PresConfig) font.Face {
+	if !a.has(Smallcaps) {
+		return face
+	}
+	return a.face(size*0.8, cfg)
+}
+
+/* smallcaps maps lowercase `r` to its capital in the `small` face, if `a` is Smallcaps */
+func smallcaps[F any](a MarkupAttribute, r rune, face, small F) (rune, F, bool) {
+	if a.has(Smallcaps) && unicode.IsLower(r) {
+		return unicode.ToUpper(r), small, true
+	}
+	return r, face, false
+}
+
+/* glyphMeasurer is the part of font.Face used for measuring */
+type glyphMeasurer interface {
+	GlyphAdvance(r rune) (fixed.Int26_6, bool)
+	Kern(r0, r1 rune) fixed.Int26_6
+}
+
+/* tabAdvance returns the advance of a tab at `x` from the start of the line, or of the run for code */
+func (c PresConfig) tabAdvance(face glyphMeasurer, x fixed.Int26_6) fixed.Int26_6 {
+	adv, _ := face.GlyphAdvance(' ')
+	width := adv * fixed.Int26_6(c.TabSize)
+	if c.TabStops && width > 0 {
+		return width - x%width
+	}
+	return width
+}
+
 // measureText was misspelled as MessureText; fixed and call sites updated.
-func (a MarkupAttribute) measureText(s string, size float64, cfg PresConfig) fixed.Int26_6 {
+// `start` is the offset of `s` from the start of the line, needed for tab-stops.
+func (a MarkupAttribute) measureText(s string, start fixed.Int26_6, size float64, cfg PresConfig) fixed.Int26_6 {
+	if a.has(Math) {
+		return a.mathBox(s, size, cfg).width
+	}
 	var x fixed.Int26_6
-	face := a.face(size, cfg)
+	face := a.cachedFace(size, cfg)
+	tracking := a.tracking(size, cfg)
+	small := face
+	if a.has(Smallcaps) {
+		small = a.cachedFace(size*0.8, cfg)
+	}
 	prevRune := rune(-1)
+	prevSmall := false
 	for _, r := range s {
-		if prevRune != -1 {
-			x += face.Kern(prevRune, r)
+		r, f, isSmall := smallcaps(a, r, face, small)
+		if prevRune != -1 && cfg.Kerning && isSmall == prevSmall {
+			x += f.Kern(prevRune, r)
 		}
 		switch r {
 		case '\t':
-			adv, _ := face.GlyphAdvance(' ')
-			x += adv * fixed.Int26_6(cfg.TabSize)
+			/* code aligns to monospace columns of its own, not to the proportional text before it */
+			col := start + x
+			if a.has(Code) {
+				col = x
+			}
+			x += cfg.tabAdvance(face, col)
 		default:
-			adv, _ := face.GlyphAdvance(r)
-			x += adv
+			adv, _ := f.GlyphAdvance(r)
+			x += adv + tracking
 		}
-		prevRune = r
+		prevRune, prevSmall = r, isSmall
 	}
 	return x
 }
 
-func (m MarkupText) words() iter.Seq2[MarkupAttribute, []rune] {
-	return func(yield func(MarkupAttribute, []rune) bool) {
+/* tracking returns the extra advance after every glyph of `a` in text of `size` */
+func (a MarkupAttribute) tracking(size float64, cfg PresConfig) fixed.Int26_6 {
+	if !a.has(BigText) || cfg.TitleTracking == 0 {
+		return 0
+	}
+	return fixed.Int26_6(cfg.pixels(a.faceSize(size, cfg)) * cfg.TitleTracking * 64)
+}
+
+/* words yields the words and runs of whitespace of `m`, as substrings without copying */
+func (m MarkupText) words() iter.Seq2[MarkupAttribute, string] {
+	return func(yield func(MarkupAttribute, string) bool) {
 		for _, part := range m {
-			if part.Attr&(Code|BigText|NoWrap) != 0 {
+			if part.Attr&(Code|BigText|NoWrap|Footnote|Math) != 0 {
 				/* do not split code-sections when code-section of bigtext-section */
-				if !yield(part.Attr, []rune(part.Text)) {
+				if !yield(part.Attr, part.Text) {
 					return
 				}
 				continue
 			}
 
-			var runes []rune
+			start := 0
 			wasSpace := false
-			for _, r := range part.Text {
+			for i, r := range part.Text {
 				isSpace := unicode.IsSpace(r)
-				if len(runes) > 0 && wasSpace != isSpace {
-					if !yield(part.Attr, runes) {
+				if i > start && wasSpace != isSpace {
+					if !yield(part.Attr, part.Text[start:i]) {
 						return
 					}
-					runes = runes[:0]
+					start = i
 				}
 				wasSpace = isSpace
-				runes = append(runes, r)
 			}
-			if len(runes) > 0 && !yield(part.Attr, runes) {
+			if start < len(part.Text) && !yield(part.Attr, part.Text[start:]) {
 				return
 			}
 		}
 	}
 }
 
-func (m MarkupText) wrapLines(bounds image.Rectangle, size float64, cfg PresConfig) iter.Seq2[fixed.Int26_6, MarkupText] {
-	return func(yield func(fixed.Int26_6, MarkupText) bool) {
+/* wrapLines breaks `m` into visual lines, the first `indented` lines are shortened by `indent` */
+func (m MarkupText) wrapLines(bounds image.Rectangle, size float64, cfg PresConfig, indent fixed.Int26_6, indented int) iter.Seq[wrappedLine] {
+	return func(yield func(wrappedLine) bool) {
 		var width fixed.Int26_6
-		var line MarkupText
-		for attr, word := range m.words() {
-			if nl := slices.Index(word, '\n'); nl != -1 {
-				if !yield(width, line) {
-					return
-				}
-				if !yield(0, nil) {
-					return
-				}
-				line = nil
-				width = 0
-				word = word[nl+1:]
-				if len(word) == 0 {
+		var line MarkupText /* reused, copied when yielded */
+		n := 0              /* amount of text-lines yielded */
+		emit := func(w fixed.Int26_6, l MarkupText) bool {
+			if l != nil {
+				n++
+			}
+			return yield(wrappedLine{width: w, text: l})
+		}
+		/* take returns a copy of the current line, nil if empty, and clears it */
+		take := func() MarkupText {
+			if len(line) == 0 {
+				return nil
+			}
+			l := slices.Clone(line)
+			line = line[:0]
+			return l
+		}
+		limit := func() int {
+			if n < indented {
+				return bounds.Dx() - indent.Ceil()
+			}
+			return bounds.Dx()
+		}
+		/* place appends `word` to the line, wrapping first if needed, and reports whether to continue */
+		place := func(attr MarkupAttribute, word string) bool {
+			adv := attr.measureText(word, width, size, cfg)
+			wrapped := false
+			for (width + adv).Ceil() > limit() {
+				/* fill the line up to a syllable-boundary before moving the word down */
+				if head, tail, hadv, ok := cfg.hyphenate(attr, word, width, limit(), size); ok {
+					line = append(line, Markup{attr, head})
+					if !emit(width+hadv, take()) {
+						return false
+					}
+					width, word = 0, tail
+					adv = attr.measureText(word, 0, size, cfg)
 					continue
 				}
-			}
-			adv := attr.measureText(string(word), size, cfg)
-			if (width + adv).Ceil() > bounds.Dx() {
+				if wrapped {
+					/* overflows even a line of its own */
+					break
+				}
 				if width == 0 {
 					/* only one word already exceeds the line */
-					yield(-1, nil)
-					return
+					yield(wrappedLine{width: -1})
+					return false
 				}
-				if !yield(width, line) {
-					return
+				if !emit(width, take()) {
+					return false
 				}
 
-				line = nil
-				width = 0
-				if unicode.IsSpace(word[0]) {
-					continue
+				width, wrapped = 0, true
+				if r, _ := utf8.DecodeRuneInString(word); unicode.IsSpace(r) {
+					break
+				}
+				if cfg.TabStops {
+					adv = attr.measureText(word, 0, size, cfg)
 				}
 			}
+			if r, _ := utf8.DecodeRuneInString(word); wrapped && width == 0 && unicode.IsSpace(r) {
+				return true
+			}
 			width += adv
-			line = append(line, Markup{attr, string(word)})
+			line = append(line, Markup{attr, word})
+			return true
+		}
+		for attr, word := range m.words() {
+			/* code, big and nowrap-runs are never wrapped, but do break at explicit newlines */
+			for {
+				head, rest, sep := cutLine(word)
+				/* whitespace before a newline is dropped */
+				if head != "" && !(sep != 0 && strings.TrimSpace(head) == "") && !place(attr, head) {
+					return
+				}
+				if sep == 0 {
+					break
+				}
+				if sep == lineBreak {
+					/* only the line ends, not the paragraph */
+					l := take()
+					if l != nil {
+						n++
+					}
+					if !yield(wrappedLine{width: width, text: l, broken: true}) {
+						return
+					}
+				} else if !emit(width, take()) || !emit(0, nil) {
+					return
+				}
+				width = 0
+				word = rest
+			}
 		}
-		if !yield(width, line) {
+		if !emit(width, take()) {
 			return
 		}
 	}
 }
 
+/* cutLine cuts `s` around its first newline or lineBreak, `sep` is 0 if it has none */
+func cutLine(s string) (head, rest string, sep rune) {
+	i := strings.IndexAny(s, "\n"+string(lineBreak))
+	if i < 0 {
+		return s, "", 0
+	}
+	sep, size := utf8.DecodeRuneInString(s[i:])
+	return s[:i], s[i+size:], sep
+}
+
 func (m MarkupText) height(size float64, cfg PresConfig) (h, asc fixed.Int26_6) {
+	var desc fixed.Int26_6
+	hasMath := false
+	for _, part := range m {
+		met := part.Attr.cachedFace(size, cfg).Metrics()
+		h = max(h, met.Height)
+		asc = max(asc, met.Ascent)
+		desc = max(desc, met.Descent)
+		if part.Attr.has(Math) {
+			box := part.Attr.mathBox(part.Text, size, cfg)
+			asc = max(asc, box.ascent)
+			desc = max(desc, box.descent)
+			hasMath = true
+		}
+	}
+	if hasMath {
+		/* fractions and scripts may be taller than a line of text */
+		h = max(h, asc+desc)
+	}
+	return
+}
+
+/* xHeight returns the largest x-height of `m`, used to center runs with BaselineCenter */
+func (m MarkupText) xHeight(size float64, cfg PresConfig) (xh fixed.Int26_6) {
 	for _, part := range m {
-		face := part.Attr.face(size, cfg)
-		h = max(h, face.Metrics().Height)
-		asc = max(asc, face.Metrics().Ascent)
+		xh = max(xh, xHeightOf(part.Attr.cachedFace(size, cfg).Metrics()))
 	}
 	return
 }
 
+/* baselineRaise returns how far a run with metrics `met` is raised on a line of x-height `lineXHeight` */
+func (c PresConfig) baselineRaise(lineXHeight fixed.Int26_6, met font.Metrics) fixed.Int26_6 {
+	if c.Baseline != BaselineCenter {
+		return 0
+	}
+	return (lineXHeight - xHeightOf(met)) / 2
+}
+
+/* xHeightOf returns the x-height of `met`, estimated from the ascent if the font lacks one */
+func xHeightOf(met font.Metrics) fixed.Int26_6 {
+	if met.XHeight > 0 {
+		return met.XHeight
+	}
+	return met.Ascent / 2
+}
+
 // Huidige runs voor lijnen
 type lineRun struct {
 	underline bool
 	active    bool
 	start     fixed.Int26_6
 	face      font.Face
+	fill      image.Image
+	thickness float64       /* fraction of font height */
+	position  float64       /* strikethrough height as fraction of ascent */
+	raise     fixed.Int26_6 /* of the baseline of the run, see baselineRaise */
 }
 
 // helper om een run te sluiten en te tekenen tot currentX
@@ -329,15 +653,15 @@ func (run *lineRun) closeRun(dot fixed.Point26_6) (image.Rectangle, bool) {
 		return image.Rectangle{}, false
 	}
 	met := run.face.Metrics()
-	// dynamische dikte: ~5% van font height, min 1px
-	thick := max(met.Height.Ceil()/20, 1)
+	// dynamische dikte: fractie van font height, min 1px
+	thick := max(int(float64(met.Height.Ceil())*run.thickness), 1)
 	var y fixed.Int26_6
 	if run.underline {
 		// iets onder de baseline
-		y = dot.Y + fixed.I(thick)
+		y = dot.Y - run.raise + fixed.I(thick)
 	} else {
 		// strikethrough ongeveer halverwege de x-height (≈ helft van ascent)
-		y = dot.Y - met.Ascent/3
+		y = dot.Y - run.raise - fixed.Int26_6(float64(met.Ascent)*run.position)
 	}
 	run.active = false
 	if dot.X <= run.start {
@@ -353,6 +677,55 @@ func (run *lineRun) closeRun(dot fixed.Point26_6) (image.Rectangle, bool) {
 	return image.Rect(x0px, ypx, x1px, ypx+thick), true
 }
 
+var warned sync.Map /* messages already reported by warnOnce */
+
+/* warnOnce reports a warning on stderr, unless the same warning was reported before */
+func warnOnce(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if _, seen := warned.LoadOrStore(msg, struct{}{}); !seen {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
+
+/* clipImage restricts drawing on `img` to `r`, if `img` supports sub-images */
+func clipImage(img draw.Image, r image.Rectangle) draw.Image {
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return img
+	}
+	if clipped, ok := sub.SubImage(r).(draw.Image); ok {
+		return clipped
+	}
+	return img
+}
+
+/* drawGlyph draws the glyph-mask in `fill`, surrounded by a halo of `outline` px if cfg.Outline is set */
+func drawGlyph(img draw.Image, dr image.Rectangle, mask image.Image, maskp image.Point, outline int, fill image.Image, cfg PresConfig) {
+	if cfg.Outline != nil {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if dx == 0 && dy == 0 {
+					continue
+				}
+				off := image.Pt(dx*outline, dy*outline)
+				draw.DrawMask(img, dr.Add(off), cfg.Outline, image.Point{}, mask, maskp, draw.Over)
+			}
+		}
+	}
+	draw.DrawMask(img, dr, fill, dr.Min, mask, maskp, draw.Over)
+}
+
+/* hideFrom returns a copy of `m` with the parts from `part` on laid out but not drawn */
+func (m MarkupText) hideFrom(part int) MarkupText {
+	m = slices.Clone(m)
+	for i := part; i < len(m); i++ {
+		m[i].Attr |= Hidden
+	}
+	return m
+}
+
 func (m MarkupText) String() string {
 	var buf strings.Builder
 	for _, parts := range m {
@@ -361,62 +734,295 @@ func (m MarkupText) String() string {
 	return buf.String()
 }
 
-func (m MarkupText) totalHeight(bounds image.Rectangle, size float64, cfg PresConfig) (totalHeight fixed.Int26_6, ok bool) {
+/* wrappedLine is a visual line of text, `text` is nil for paragraph-breaks */
+type wrappedLine struct {
+	width  fixed.Int26_6
+	height fixed.Int26_6
+	ascent fixed.Int26_6
+	indent fixed.Int26_6 /* space reserved left of the line for the drop-cap */
+	above  fixed.Int26_6 /* paragraph-spacing above the line, included in the height of the layout */
+	text   MarkupText
+	broken bool /* ended by a lineBreak, the paragraph continues on the next line */
+}
+
+/* textLayout is a MarkupText wrapped and measured at a specific size */
+type textLayout struct {
+	lines   []wrappedLine
+	height  fixed.Int26_6
+	dropCap rune /* initial drawn in front of the first lines, 0 if none */
+	capFace font.Face
+	capAttr MarkupAttribute
+}
+
+/* splitDropCap splits the leading letter off `m` */
+func (m MarkupText) splitDropCap() (MarkupAttribute, rune, MarkupText, bool) {
+	if len(m) == 0 {
+		return 0, 0, nil, false
+	}
+	r, sz := utf8.DecodeRuneInString(m[0].Text)
+	if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return 0, 0, nil, false
+	}
+	rest := slices.Clone(m)
+	rest[0].Text = rest[0].Text[sz:]
+	if rest[0].Text == "" {
+		rest = rest[1:]
+	}
+	return m[0].Attr, r, rest, true
+}
+
+/* layout wraps `m` once at `size` and measures every resulting line */
+func (m MarkupText) layout(bounds image.Rectangle, size float64, cfg PresConfig) (lay textLayout, ok bool) {
 	ok = true
-	for w, text := range m.wrapLines(bounds, size, cfg) {
-		if w == -1 {
+	var indent fixed.Int26_6
+	if cfg.DropCap > 0 {
+		if attr, r, rest, found := m.splitDropCap(); found {
+			lay.dropCap = r
+			lay.capAttr = attr
+			/* grow the cap-height (~0.7em) over the line-height (~1.2em) of the spanned lines */
+			lay.capFace = attr.face(size*(1+1.7*float64(cfg.DropCap-1)), cfg)
+			adv, _ := lay.capFace.GlyphAdvance(r)
+			indent = adv + fixed.I(int(cfg.pixels(size)/4))
+			m = rest
+		}
+	}
+	for line := range m.wrapLines(bounds, size, cfg, indent, cfg.DropCap) {
+		if line.width == -1 {
 			ok = false
 		}
-		if text == nil {
-			totalHeight += fixed.I(int(size * cfg.NewlineSpacing))
+		lay.lines = append(lay.lines, line)
+	}
+	if cfg.Widows {
+		avoidWidows(lay.lines, bounds, size, cfg, indent)
+	}
+	textLines := 0
+	heading := false /* the last line of text is a heading */
+	for i := range lay.lines {
+		line := &lay.lines[i]
+		if line.text == nil {
+			line.height = fixed.I(int(cfg.pixels(size) * cfg.NewlineSpacing))
+			lay.height += line.height
+			continue
+		}
+		line.height, line.ascent = line.text.height(size, cfg)
+		if heading && !line.text.isHeading() {
+			line.above = fixed.I(int(cfg.pixels(size) * cfg.ParagraphSpacing))
+			lay.height += line.above
+		}
+		heading = line.text.isHeading()
+		if textLines < cfg.DropCap {
+			line.indent = indent
+		}
+		lay.height += line.height
+		textLines++
+	}
+	return
+}
+
+/* isHeading reports whether line `m` is set in big text entirely */
+func (m MarkupText) isHeading() bool {
+	found := false
+	for _, part := range m {
+		if part.isSpace() {
 			continue
 		}
-		h, _ := text.height(size, cfg)
-		totalHeight += h
+		if !part.Attr.has(BigText) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+/* width measures `m` as a single line */
+func (m MarkupText) width(size float64, cfg PresConfig) (w fixed.Int26_6) {
+	for _, part := range m {
+		w += part.Attr.measureText(part.Text, w, size, cfg)
 	}
 	return
 }
 
-func (m MarkupText) findSize(bounds image.Rectangle, cfg PresConfig) (size float64, height fixed.Int26_6) {
+/* isSpace reports whether `part` is a run of whitespace as yielded by words */
+func (part Markup) isSpace() bool {
+	return part.Text != "" && strings.TrimSpace(part.Text) == ""
+}
+
+/* trimTrailingSpace cuts runs of whitespace off the end of `m` */
+func (m MarkupText) trimTrailingSpace() MarkupText {
+	for len(m) > 0 && m[len(m)-1].isSpace() {
+		m = m[:len(m)-1]
+	}
+	return m
+}
+
+/* lastWord returns the index of the first part of the last word in `m`, 0 if `m` is a single word */
+func (m MarkupText) lastWord() int {
+	for i := len(m) - 1; i >= 0; i-- {
+		if m[i].isSpace() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+/* avoidWidows pulls the last word of the previous line down onto paragraphs ending in a single word, if it fits.
+ * Code, big and nowrap-runs are a single word, so they are moved as a whole. */
+func avoidWidows(lines []wrappedLine, bounds image.Rectangle, size float64, cfg PresConfig, indent fixed.Int26_6) {
+	textLines := 0
+	for i := range lines {
+		if lines[i].text == nil {
+			continue
+		}
+		textLines++
+		if i == 0 || lines[i-1].text == nil || lines[i-1].broken || (i+1 < len(lines) && lines[i+1].text != nil) {
+			continue
+		}
+		prev, cur := lines[i-1].text.trimTrailingSpace(), lines[i].text.trimTrailingSpace()
+		start := prev.lastWord()
+		if cur.lastWord() != 0 || start == 0 {
+			continue
+		}
+		keep := prev[:start].trimTrailingSpace()
+		if len(keep) == 0 {
+			continue
+		}
+		moved := slices.Concat(prev[start:], MarkupText{prev[start-1]}, cur)
+		limit := fixed.I(bounds.Dx())
+		if textLines <= cfg.DropCap {
+			limit -= indent
+		}
+		w := moved.width(size, cfg)
+		if w.Ceil() > limit.Ceil() {
+			continue
+		}
+		lines[i-1].text, lines[i-1].width = keep, keep.width(size, cfg)
+		lines[i].text, lines[i].width = moved, w
+	}
+}
+
+func (m MarkupText) findSize(bounds image.Rectangle, cfg PresConfig) (size float64, lay textLayout) {
 	if len(m) == 0 {
 		return
 	}
-	lo := float64(1)
-	hi := float64(1)
-	for {
-		h, ok := m.totalHeight(bounds, hi, cfg)
-		if !ok || h.Ceil() >= bounds.Dy() {
-			break
+	size = largestSize(func(size float64) bool {
+		l, ok := m.layout(bounds, size, cfg)
+		if !ok || l.height.Ceil() >= bounds.Dy() {
+			return false
 		}
-		lo = hi
-		hi *= 2
-	}
+		lay = l
+		return true
+	})
+	return
+}
 
-	for i := lo; i < hi; i += 0.5 {
-		h, ok := m.totalHeight(bounds, i, cfg)
-		if !ok || h.Ceil() >= bounds.Dy() {
-			break
+/* largestSize returns the largest size in steps of 0.5 for which `fits` holds, 0 if not even 1 does.
+ * The size is doubled until it does not fit anymore, then bisected. */
+func largestSize(fits func(size float64) bool) float64 {
+	if !fits(1) {
+		return 0
+	}
+	lo, hi := 1.0, 2.0
+	for fits(hi) {
+		lo, hi = hi, hi*2
+	}
+	for hi-lo > 0.5 {
+		mid := math.Floor(lo+hi) / 2 /* on the grid of 0.5 */
+		if fits(mid) {
+			lo = mid
+		} else {
+			hi = mid
 		}
+	}
+	return lo
+}
 
-		height = h
-		size = i
+/* fit chooses the font-size of `m` in `bounds` and lays it out, `overflows` reports text not fitting at min-font-size */
+func (m MarkupText) fit(bounds image.Rectangle, cfg PresConfig) (size float64, lay textLayout, overflows bool) {
+	if cfg.FontSize != 0 {
+		size = cfg.points(cfg.FontSize, cfg.FontUnit, bounds)
+		lay, _ = m.layout(bounds, size, cfg)
+		return
+	}
+	size, lay = m.findSize(bounds, cfg)
+	if size == 0 && len(m) > 0 {
+		/* too tall even at the smallest size, draw it anyway and let it overflow */
+		size = 1
+		lay, _ = m.layout(bounds, size, cfg)
+		overflows = true
+	}
+	if cfg.MaxFontSize > 0 && size > cfg.points(cfg.MaxFontSize, cfg.MaxFontUnit, bounds) {
+		size = cfg.points(cfg.MaxFontSize, cfg.MaxFontUnit, bounds)
+		lay, _ = m.layout(bounds, size, cfg)
+	}
+	if cfg.MinFontSize > 0 && size < cfg.points(cfg.MinFontSize, cfg.MinFontUnit, bounds) {
+		size = cfg.points(cfg.MinFontSize, cfg.MinFontUnit, bounds)
+		lay, _ = m.layout(bounds, size, cfg)
+		overflows = true
 	}
 	return
 }
 
+/* Layout is MarkupText wrapped into a text-box, as drawn by Draw */
+type Layout struct {
+	Size      float64      /* font-size in points */
+	Lines     []MarkupText /* visual lines, nil for paragraph-breaks */
+	Height    int          /* px */
+	Overflows bool         /* text does not fit at min-font-size */
+}
+
+/* LayoutMarkup wraps `text` into `bounds` like Draw would, without drawing */
+func LayoutMarkup(text MarkupText, bounds image.Rectangle, cfg PresConfig) Layout {
+	size, lay, overflows := text.fit(cfg.Padding.Apply(bounds), cfg)
+	l := Layout{Size: size, Height: lay.height.Ceil(), Overflows: overflows}
+	for _, line := range lay.lines {
+		l.Lines = append(l.Lines, line.text)
+	}
+	return l
+}
+
+/* Fits reports whether `m` fits `bounds` at `size` points without drawing, and the height in px it takes there */
+func (m MarkupText) Fits(bounds image.Rectangle, size float64, cfg PresConfig) (fits bool, height int) {
+	bounds = cfg.Padding.Apply(bounds)
+	lay, ok := m.layout(bounds, size, cfg)
+	height = lay.height.Ceil()
+	return ok && height <= bounds.Dy(), height
+}
+
 func (m MarkupText) Draw(img draw.Image, bounds image.Rectangle, cfg PresConfig) {
-	bounds = cfg.Margin.Apply(bounds)
+	m.DrawContext(context.Background(), img, bounds, cfg)
+}
 
-	var totalHeight fixed.Int26_6
-	size := cfg.FontSize
-	if size == 0 {
-		size, totalHeight = m.findSize(bounds, cfg)
-	} else {
-		area := float64(bounds.Dx()*bounds.Dx() + bounds.Dy()*bounds.Dy())
-		size = size * math.Sqrt(area) / 100
-		totalHeight, _ = m.totalHeight(bounds, size, cfg)
+/* DrawContext is Draw, but stops between lines with an error once `ctx` is done */
+func (m MarkupText) DrawContext(ctx context.Context, img draw.Image, bounds image.Rectangle, cfg PresConfig) error {
+	bounds = cfg.Padding.Apply(bounds)
+	if fill, ok := cfg.Foreground.(spanner); ok {
+		cfg.Foreground = fill.span(bounds)
+	}
+	if cfg.TextOpacity < 1 {
+		cfg.Foreground = fade(cfg.Foreground, cfg.TextOpacity)
+		cfg.Outline = fade(cfg.Outline, cfg.TextOpacity)
+		faded := make(map[MarkupAttribute]image.Image, len(cfg.AttrColors))
+		for attr, fill := range cfg.AttrColors {
+			faded[attr] = fade(fill, cfg.TextOpacity)
+		}
+		cfg.AttrColors = faded
+	}
+	if cfg.WritingMode == WritingVertical {
+		return m.drawVertical(ctx, img, bounds, cfg)
 	}
 
+	size, lay, overflows := m.fit(bounds, cfg)
+	if overflows {
+		switch cfg.FontOverflow {
+		case OverflowClip:
+			img = clipImage(img, bounds)
+		case OverflowWarn:
+			warnOnce("text does not fit at min-font-size: %.20q", m.String())
+		}
+	}
+	totalHeight := lay.height
+	outline := max(int(cfg.pixels(size)/30), 1)
+
 	var dot fixed.Point26_6
 	var yOffset fixed.Int26_6
 
@@ -429,47 +1035,82 @@ func (m MarkupText) Draw(img draw.Image, bounds image.Rectangle, cfg PresConfig)
 		yOffset = fixed.I(bounds.Dy()) - totalHeight
 	}
 
-	for width, text := range m.wrapLines(bounds, size, cfg) {
-		if text == nil {
-			yOffset += fixed.I(int(size * cfg.NewlineSpacing))
+	var capX, capTop, capBaseline fixed.Int26_6
+	textLines := 0
+	for _, line := range lay.lines {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("drawing text: %w", err)
+		}
+		if line.text == nil {
+			yOffset += line.height
 			continue
 		}
-		h, asc := text.height(size, cfg)
+		yOffset += line.above
+		width, text, h, asc := line.width, line.text, line.height, line.ascent
 
 		switch cfg.Align {
 		case Left:
-			dot.X = 0
+			dot.X = line.indent
 		case Center:
-			dot.X = fixed.I(bounds.Dx()/2) - width/2
+			dot.X = line.indent + (fixed.I(bounds.Dx())-line.indent)/2 - width/2
 		case Right:
 			dot.X = fixed.I(bounds.Dx()) - width
 		}
 		dot.Y = yOffset + asc
+		lineStart := dot.X
+
+		/* the drop-cap sits on the baseline of the last indented line */
+		if textLines == 0 {
+			capX = dot.X - line.indent
+			capTop = yOffset
+		}
+		if textLines < cfg.DropCap {
+			capBaseline = dot.Y
+		}
+		textLines++
 
 		prevRune := rune(-1)
+		prevAttr := MarkupAttribute(0)
 
-		ul := lineRun{underline: true}  // underline-run
-		st := lineRun{underline: false} // strikethrough-run
+		ul := lineRun{underline: true, thickness: cfg.UnderlineThickness}                             // underline-run
+		st := lineRun{underline: false, thickness: cfg.StrikeThickness, position: cfg.StrikePosition} // strikethrough-run
+
+		var lineXHeight fixed.Int26_6
+		if cfg.Baseline == BaselineCenter {
+			lineXHeight = text.xHeight(size, cfg)
+		}
 
-		for _, part := range text {
+		prevSmall := false
+		for i, part := range text {
 			face := part.Attr.face(size, cfg)
+			small := part.Attr.smallFace(face, size, cfg)
+			tracking := part.Attr.tracking(size, cfg)
+			raise := cfg.baselineRaise(lineXHeight, face.Metrics())
+			if i > 0 && !part.Attr.sameFace(prevAttr, cfg) {
+				/* do not kern against a glyph of a different face */
+				prevRune = -1
+			}
+			prevAttr = part.Attr
+			partStart := dot.X
 
 			// start/stop runs op stijlwissel per part
-			hasUL := part.Attr&Underline != 0
-			hasST := part.Attr&Strikethrough != 0
+			hasUL := part.Attr&(Underline|Hidden) == Underline
+			hasST := part.Attr&(Strikethrough|Hidden) == Strikethrough
 
 			// start underline-run als nodig
 			if hasUL && !ul.active {
 				ul.active = true
 				ul.start = dot.X
 				ul.face = face
+				ul.raise = raise
+				ul.fill = cfg.fill(part.Attr)
 			}
 			// sluit underline-run als stijl wegvalt
 			if !hasUL && ul.active {
 				line, ok := ul.closeRun(dot)
 				if ok {
 					line = line.Add(bounds.Min)
-					draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+					draw.Draw(img, line, ul.fill, line.Min, draw.Over)
 				}
 			}
 
@@ -478,14 +1119,26 @@ func (m MarkupText) Draw(img draw.Image, bounds image.Rectangle, cfg PresConfig)
 				st.active = true
 				st.start = dot.X
 				st.face = face
+				st.raise = raise
+				st.fill = cfg.fill(part.Attr)
 			}
 			// sluit strikethrough-run als stijl wegvalt
 			if !hasST && st.active {
 				line, ok := st.closeRun(dot)
 				if ok {
 					line = line.Add(bounds.Min)
-					draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+					draw.Draw(img, line, st.fill, line.Min, draw.Over)
+				}
+			}
+
+			if part.Attr.has(Math) {
+				box := part.Attr.mathBox(part.Text, size, cfg)
+				if !part.Attr.has(Hidden) {
+					box.draw(img, bounds.Min, dot.Sub(fixed.Point26_6{Y: raise}), outline, cfg.fill(part.Attr), cfg)
 				}
+				dot.X += box.width
+				prevRune = -1
+				continue
 			}
 
 			for _, r := range part.Text {
@@ -495,37 +1148,50 @@ func (m MarkupText) Draw(img draw.Image, bounds image.Rectangle, cfg PresConfig)
 						line, ok := ul.closeRun(dot)
 						if ok {
 							line = line.Add(bounds.Min)
-							draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+							draw.Draw(img, line, ul.fill, line.Min, draw.Over)
 						}
 					}
 					if st.active {
 						line, ok := st.closeRun(dot)
 						if ok {
 							line = line.Add(bounds.Min)
-							draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+							draw.Draw(img, line, st.fill, line.Min, draw.Over)
 						}
 					}
 					yOffset += h
 					dot.X = 0
 					dot.Y = yOffset + asc
+					lineStart, partStart = 0, 0
 					prevRune = -1
 					continue
 				}
-				if prevRune != -1 {
-					dot.X += face.Kern(prevRune, r)
+				r, f, isSmall := smallcaps(part.Attr, r, face, small)
+				if prevRune != -1 && cfg.Kerning && isSmall == prevSmall {
+					dot.X += f.Kern(prevRune, r)
 				}
 
 				switch r {
 				case '\t':
-					advSpace, _ := face.GlyphAdvance(' ')
-					dot.X += advSpace * fixed.Int26_6(cfg.TabSize)
+					col := dot.X - lineStart
+					if part.Attr.has(Code) {
+						col = dot.X - partStart
+					}
+					dot.X += cfg.tabAdvance(face, col)
 				default:
-					dr, mask, maskp, advance, _ := face.Glyph(dot, r)
-					dr = dr.Add(bounds.Min)
-					draw.DrawMask(img, dr, cfg.Foreground, image.Point{}, mask, maskp, draw.Over)
-					dot.X += advance
+					gdot := dot
+					gdot.Y -= raise
+					if part.Attr&Footnote != 0 {
+						/* raise superscript to the top of the line */
+						gdot.Y -= asc * 2 / 5
+					}
+					dr, mask, maskp, advance, _ := f.Glyph(gdot, r)
+					if !part.Attr.has(Hidden) {
+						dr = dr.Add(bounds.Min)
+						drawGlyph(img, dr, mask, maskp, outline, cfg.fill(part.Attr), cfg)
+					}
+					dot.X += advance + tracking
 				}
-				prevRune = r
+				prevRune, prevSmall = r, isSmall
 			}
 		}
 
@@ -534,17 +1200,27 @@ func (m MarkupText) Draw(img draw.Image, bounds image.Rectangle, cfg PresConfig)
 			line, ok := ul.closeRun(dot)
 			if ok {
 				line = line.Add(bounds.Min)
-				draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+				draw.Draw(img, line, ul.fill, line.Min, draw.Over)
 			}
 		}
 		if st.active {
 			line, ok := st.closeRun(dot)
 			if ok {
 				line = line.Add(bounds.Min)
-				draw.Draw(img, line, cfg.Foreground, image.Point{}, draw.Src)
+				draw.Draw(img, line, st.fill, line.Min, draw.Over)
 			}
 		}
 
 		yOffset += h
 	}
+
+	if lay.dropCap != 0 {
+		gb, _, _ := lay.capFace.GlyphBounds(lay.dropCap)
+		/* never let the drop-cap rise above the first line */
+		capBaseline = max(capBaseline, capTop-gb.Min.Y)
+		dr, mask, maskp, _, _ := lay.capFace.Glyph(fixed.Point26_6{X: capX, Y: capBaseline}, lay.dropCap)
+		dr = dr.Add(bounds.Min)
+		drawGlyph(img, dr, mask, maskp, outline, cfg.fill(lay.capAttr), cfg)
+	}
+	return nil
 }
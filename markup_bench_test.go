@@ -0,0 +1,67 @@
+package slab
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+/* benchMarkup builds the MarkupText of `lines` as the parser would */
+func benchMarkup(lines ...string) MarkupText {
+	var b MarkupBuilder
+	for _, line := range lines {
+		b.FeedLine(line, LineJoinSpace)
+	}
+	return b.Text()
+}
+
+func benchLayout(b *testing.B, text MarkupText) {
+	cfg := defaultConf()
+	bounds := image.Rect(0, 0, 1024, 768)
+	b.ReportAllocs()
+	for b.Loop() {
+		LayoutMarkup(text, bounds, cfg)
+	}
+}
+
+func BenchmarkLayoutDenseParagraph(b *testing.B) {
+	text := benchMarkup(strings.Repeat("Lorem ipsum dolor sit amet, **consectetur** adipiscing elit, sed do *eiusmod* tempor incididunt ut labore. ", 20))
+	benchLayout(b, text)
+}
+
+func BenchmarkLayoutShortLines(b *testing.B) {
+	var lines MarkupBuilder
+	for i := range 40 {
+		lines.FeedLine("item "+strings.Repeat("x", i%7+1), LineJoinBreak)
+	}
+	benchLayout(b, lines.Text())
+}
+
+func BenchmarkLayoutCodeBlock(b *testing.B) {
+	var code MarkupBuilder
+	for range 25 {
+		code.FeedLine("`\tif err := run(ctx, args); err != nil { return fmt.Errorf(\"run: %w\", err) }`", LineJoinBreak)
+	}
+	benchLayout(b, code.Text())
+}
+
+/* longParagraph is a few hundred words of mixed styles without line-breaks */
+var longParagraph = benchMarkup(strings.Repeat("The quick brown fox **jumps** over the _lazy_ dog, then `naps` for a while. ", 40))
+
+func BenchmarkWords(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		for range longParagraph.words() {
+		}
+	}
+}
+
+func BenchmarkWrapLines(b *testing.B) {
+	cfg := defaultConf()
+	bounds := image.Rect(0, 0, 1024, 768)
+	b.ReportAllocs()
+	for b.Loop() {
+		for range longParagraph.wrapLines(bounds, 12, cfg, 0, 0) {
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package slab
+
+import (
+	"image"
+	"image/color"
+	"slices"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestInlineMath(t *testing.T) {
+	tests := []struct {
+		line   string
+		inline bool
+		math   []string
+	}{
+		{"costs $5 and $HOME/$PATH", false, nil},
+		{"costs $5 and $HOME/$PATH", true, []string{"5 and $HOME/"}},
+		{"area $x^2$ here", false, nil},
+		{"area $x^2$ here", true, []string{"x^2"}},
+		{"price $ 5$", true, nil},
+		{"from $5 to $10", true, nil},
+		{`escaped \$x$`, true, nil},
+		{`$$\frac{a}{b}$$`, false, []string{`\frac{a}{b}`}},
+	}
+	for _, tt := range tests {
+		b := MarkupBuilder{InlineMath: tt.inline}
+		b.FeedLine(tt.line, LineJoinSpace)
+		var math []string
+		for _, part := range b.Text() {
+			if part.Attr.has(Math) {
+				math = append(math, part.Text)
+			}
+		}
+		if len(math) != len(tt.math) {
+			t.Errorf("%q (inline %v): math %q, want %q", tt.line, tt.inline, math, tt.math)
+			continue
+		}
+		for i := range math {
+			if math[i] != tt.math[i] {
+				t.Errorf("%q (inline %v): math %q, want %q", tt.line, tt.inline, math, tt.math)
+			}
+		}
+	}
+}
+
+func TestVerticalMath(t *testing.T) {
+	b := MarkupBuilder{InlineMath: true}
+	b.FeedLine(`縦 $\frac{a}{b}$`, LineJoinSpace)
+	lay, ok := b.Text().verticalLayout(image.Rect(0, 0, 400, 400), 20, defaultConf())
+	if !ok {
+		t.Fatal("does not fit")
+	}
+	boxes := 0
+	for _, col := range lay.columns {
+		for _, g := range col {
+			if g.math != nil {
+				boxes++
+			} else if g.attr.has(Math) {
+				t.Errorf("math is set as glyph %q", g.r)
+			}
+		}
+	}
+	if boxes != 1 {
+		t.Errorf("%d math-boxes, want 1", boxes)
+	}
+}
+
+/* wrapped returns the visual lines of `m` at `size` in a box of 1000×1000, "" for paragraph-breaks */
+func wrapped(m MarkupText, size float64) []string {
+	var lines []string
+	for l := range m.wrapLines(image.Rect(0, 0, 1000, 1000), size, defaultConf(), 0, 0) {
+		lines = append(lines, l.text.String())
+	}
+	return lines
+}
+
+func TestWrapLinesNewlinesInRuns(t *testing.T) {
+	tests := []struct {
+		name string
+		text MarkupText
+		want []string
+	}{
+		{"code", MarkupText{{Code, "one\ntwo"}}, []string{"one", "", "two"}},
+		{"nowrap", MarkupText{{NoWrap, "one two\nthree"}}, []string{"one two", "", "three"}},
+		{"big", MarkupText{{BigText, "one\n\ntwo"}}, []string{"one", "", "", "", "two"}},
+		{"trailing space", MarkupText{{Code, "one  \ntwo"}}, []string{"one  ", "", "two"}},
+		{"text around", MarkupText{{0, "before "}, {Code, "one\ntwo"}, {0, " after"}}, []string{"before one", "", "two after"}},
+		{"line-break", MarkupText{{NoWrap, "one\u2028two"}}, []string{"one", "two"}},
+	}
+	for _, tt := range tests {
+		got := wrapped(tt.text, 12)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: lines %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKerning(t *testing.T) {
+	const size = 37.25 /* a size no other test measures at, its cached pair is faked */
+	cfg := defaultConf()
+	pair := glyphKey{Markup{}.Attr.cachedFace(size, cfg).key, 'A', 'V'}
+	glyphCache.Lock()
+	glyphCache.glyphs[pair] = -fixed.I(5)
+	glyphCache.Unlock()
+	t.Cleanup(func() {
+		glyphCache.Lock()
+		delete(glyphCache.glyphs, pair)
+		glyphCache.Unlock()
+	})
+
+	a := MarkupAttribute(0)
+	apart := a.measureText("A", 0, size, cfg) + a.measureText("V", 0, size, cfg)
+	tests := []struct {
+		kerning bool
+		want    fixed.Int26_6
+	}{
+		{true, apart - fixed.I(5)},
+		{false, apart},
+	}
+	for _, tt := range tests {
+		cfg.Kerning = tt.kerning
+		if got := a.measureText("AV", 0, size, cfg); got != tt.want {
+			t.Errorf("kerning %v: advance %v, want %v", tt.kerning, got, tt.want)
+		}
+	}
+}
+
+func TestSameFaceAcrossStyles(t *testing.T) {
+	cfg := defaultConf()
+	tests := []struct {
+		a, b MarkupAttribute
+		want bool
+	}{
+		{0, Underline, true},
+		{Bold, Bold | Strikethrough, true},
+		{0, Bold, false},
+		{0, Italic, false},
+		{0, Code, false},
+		{0, BigText, false},
+		{0, Footnote, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.sameFace(tt.b, cfg); got != tt.want {
+			t.Errorf("sameFace(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTabsInMixedCode(t *testing.T) {
+	cfg := defaultConf()
+	cfg.TabStops = true
+	const size = 20
+	tabs := func(a MarkupAttribute, s string) fixed.Int26_6 {
+		return a.measureText(s, 0, size, cfg)
+	}
+	tests := []struct {
+		name string
+		text MarkupText
+		want fixed.Int26_6
+	}{
+		{"code after text", MarkupText{{0, "ab"}, {Code, "\tx"}}, tabs(0, "ab") + tabs(Code, "\tx")},
+		{"code after code", MarkupText{{Code, "ab"}, {Code, "\tx"}}, tabs(Code, "ab") + tabs(Code, "\tx")},
+		{"two tabs in code", MarkupText{{0, "prose "}, {Code, "a\tb\tc"}}, tabs(0, "prose ") + tabs(Code, "a\tb\tc")},
+		{"text after code", MarkupText{{Code, "a"}, {0, "\tx"}}, tabs(Code, "a") + MarkupAttribute(0).measureText("\tx", tabs(Code, "a"), size, cfg)},
+	}
+	for _, tt := range tests {
+		if got := tt.text.width(size, cfg); got != tt.want {
+			t.Errorf("%s: width %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	/* a tab in code reaches the same column regardless of the text before the run */
+	code := MarkupAttribute(Code)
+	if a, b := code.measureText("\tx", fixed.I(7), size, cfg), code.measureText("\tx", fixed.I(150), size, cfg); a != b {
+		t.Errorf("code-tab depends on the start of the run: %v and %v", a, b)
+	}
+	/* in text it advances to the next stop of the line */
+	var plain MarkupAttribute
+	if a, b := plain.measureText("\tx", fixed.I(7), size, cfg), plain.measureText("\tx", 0, size, cfg); a == b {
+		t.Errorf("text-tab ignores the start of the run: %v", a)
+	}
+}
+
+func TestLineJoin(t *testing.T) {
+	tests := []struct {
+		name  string
+		join  LineJoin
+		lines []string
+		want  string
+	}{
+		{"space", LineJoinSpace, []string{"one", "two"}, "one two"},
+		{"space trims around the join", LineJoinSpace, []string{"one  ", "   two"}, "one two"},
+		{"space keeps blank lines", LineJoinSpace, []string{"one", "", "two"}, "one\ntwo"},
+		{"space inside bold", LineJoinSpace, []string{"**one", "two**"}, "one two"},
+		{"break", LineJoinBreak, []string{"one", "two"}, "one\ntwo"},
+		{"break keeps indentation", LineJoinBreak, []string{"one", "  two"}, "one\n  two"},
+		{"break leaves an empty line for a blank line", LineJoinBreak, []string{"one", "", "two"}, "one\n\ntwo"},
+		{"break after %break", LineJoinBreak, []string{"one", "%break", "two"}, "one\u2028two"},
+		{"space after %break", LineJoinSpace, []string{"one", "%break", "two"}, "one\u2028two"},
+	}
+	for _, tt := range tests {
+		var b MarkupBuilder
+		for _, line := range tt.lines {
+			switch line {
+			case "":
+				b.Feed("\n")
+			case "%break":
+				b.LineBreak()
+			default:
+				b.FeedLine(line, tt.join)
+			}
+		}
+		if got := b.Text().String(); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBaselineCenter(t *testing.T) {
+	cfg := defaultConf()
+	cfg.BigText = 3
+	cfg.FontSize, cfg.FontUnit = 30, FontPoint
+	cfg.Padding = Margins{}
+	cfg.Align, cfg.VAlign = Left, Top
+	cfg.Foreground = image.Black
+	cfg.AttrColors = map[MarkupAttribute]image.Image{BigText: image.NewUniform(color.RGBA{255, 0, 0, 255})}
+	bounds := image.Rect(0, 0, 400, 200)
+
+	/* inked returns the first and last row inked by the big (red) and the small (black) run */
+	type rows struct{ top, bottom int }
+	inked := func(text MarkupText, baseline Baseline) (big, small rows) {
+		img := image.NewRGBA(bounds)
+		c := cfg
+		c.Baseline = baseline
+		text.Draw(img, bounds, c)
+		big, small = rows{-1, -1}, rows{-1, -1}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				px := img.RGBAAt(x, y)
+				if px.A < 128 {
+					continue
+				}
+				r := &small
+				if px.R > px.A/2 {
+					r = &big
+				}
+				if r.top < 0 {
+					r.top = y
+				}
+				r.bottom = y
+			}
+		}
+		if big.top < 0 || small.top < 0 {
+			t.Fatalf("%v: nothing drawn", text)
+		}
+		return
+	}
+	mid := func(r rows) int { return (r.top + r.bottom) / 2 }
+
+	plainBig, plainSmall := inked(MarkupText{{BigText, "x"}, {0, "x"}}, BaselineAlign)
+	if d := plainBig.bottom - plainSmall.bottom; d < -1 || d > 1 {
+		t.Errorf("baseline=align: small run ends at %d, big one at %d", plainSmall.bottom, plainBig.bottom)
+	}
+	big, small := inked(MarkupText{{BigText, "x"}, {0, "x"}}, BaselineCenter)
+	if big != plainBig {
+		t.Errorf("baseline=center moves the big run from %v to %v", plainBig, big)
+	}
+	if d := mid(big) - mid(small); d < -1 || d > 1 {
+		t.Errorf("baseline=center: small run centered at %d, big one at %d", mid(small), mid(big))
+	}
+	raise := plainSmall.bottom - small.bottom
+	if raise <= 0 {
+		t.Fatalf("baseline=center does not raise the small run")
+	}
+
+	/* math, underline and strikethrough move along with the run */
+	for _, tt := range []struct {
+		name string
+		attr MarkupAttribute
+	}{{"math", Math}, {"underline", Underline}, {"strikethrough", Strikethrough}} {
+		text := MarkupText{{BigText, "x"}, {tt.attr, "x"}}
+		_, alignSmall := inked(text, BaselineAlign)
+		_, centerSmall := inked(text, BaselineCenter)
+		if d := alignSmall.top - centerSmall.top - raise; d < -1 || d > 1 {
+			t.Errorf("%s: top raised from %d to %d, want by %d", tt.name, alignSmall.top, centerSmall.top, raise)
+		}
+		if d := alignSmall.bottom - centerSmall.bottom - raise; d < -1 || d > 1 {
+			t.Errorf("%s: bottom raised from %d to %d, want by %d", tt.name, alignSmall.bottom, centerSmall.bottom, raise)
+		}
+	}
+}
+
+func TestResetClearsKeepLeading(t *testing.T) {
+	b := MarkupBuilder{KeepLeading: true}
+	b.FeedLine("one", LineJoinSpace)
+	b.Reset()
+	b.Feed("\n")
+	b.FeedLine("two", LineJoinSpace)
+	if got := b.Text().String(); got != "two" {
+		t.Errorf("leading newline kept after Reset: %q", got)
+	}
+}
+
+func TestParagraphSpacing(t *testing.T) {
+	const size = 20
+	cfg := defaultConf()
+	em := fixed.I(int(cfg.pixels(size) * 1.5))
+	tests := []struct {
+		name  string
+		lines []string
+		want  fixed.Int26_6
+	}{
+		{"heading then text", []string{"==Title==", "text"}, em},
+		{"text then text", []string{"text", "text"}, 0},
+		{"heading then heading", []string{"==Title==", "==Subtitle=="}, 0},
+		{"mixed then text", []string{"==Big== small", "text"}, 0},
+		{"text then heading", []string{"text", "==Title=="}, 0},
+	}
+	bounds := image.Rect(0, 0, 1000, 1000)
+	for _, tt := range tests {
+		var b MarkupBuilder
+		for _, line := range tt.lines {
+			b.FeedLine(line, LineJoinBreak)
+		}
+		text := b.Text()
+		c := cfg
+		c.ParagraphSpacing = 0
+		without, _ := text.layout(bounds, size, c)
+		c.ParagraphSpacing = 1.5
+		with, _ := text.layout(bounds, size, c)
+		if got := with.height - without.height; got != tt.want {
+			t.Errorf("%s: height grows by %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
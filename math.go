@@ -0,0 +1,422 @@
+package slab
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+/* mathText is a symbol or a run of text in a math-expression */
+type mathText struct {
+	text   string
+	italic bool /* a variable */
+	op     bool /* a binary operator or relation, spaced apart */
+}
+
+type mathRow []mathNode
+
+/* mathScripts is `base` with a superscript and subscript, either may be nil */
+type mathScripts struct {
+	base, sup, sub mathNode
+}
+
+type mathFrac struct {
+	num, den mathNode
+}
+
+type mathSqrt struct {
+	body mathNode
+}
+
+/* mathNode is one of mathText, mathRow, mathScripts, mathFrac or mathSqrt */
+type mathNode any
+
+/* mathSymbols maps the supported TeX-commands to their characters */
+var mathSymbols = map[string]mathText{
+	"alpha": {text: "α"}, "beta": {text: "β"}, "gamma": {text: "γ"}, "delta": {text: "δ"},
+	"epsilon": {text: "ϵ"}, "varepsilon": {text: "ε"}, "zeta": {text: "ζ"}, "eta": {text: "η"},
+	"theta": {text: "θ"}, "iota": {text: "ι"}, "kappa": {text: "κ"}, "lambda": {text: "λ"},
+	"mu": {text: "μ"}, "nu": {text: "ν"}, "xi": {text: "ξ"}, "pi": {text: "π"},
+	"rho": {text: "ρ"}, "sigma": {text: "σ"}, "tau": {text: "τ"}, "upsilon": {text: "υ"},
+	"phi": {text: "ϕ"}, "varphi": {text: "φ"}, "chi": {text: "χ"}, "psi": {text: "ψ"},
+	"omega": {text: "ω"},
+	"Gamma": {text: "Γ"}, "Delta": {text: "Δ"}, "Theta": {text: "Θ"}, "Lambda": {text: "Λ"},
+	"Xi": {text: "Ξ"}, "Pi": {text: "Π"}, "Sigma": {text: "Σ"}, "Upsilon": {text: "Υ"},
+	"Phi": {text: "Φ"}, "Psi": {text: "Ψ"}, "Omega": {text: "Ω"},
+
+	"times": {text: "×", op: true}, "cdot": {text: "·", op: true}, "div": {text: "÷", op: true},
+	"pm": {text: "±", op: true}, "mp": {text: "∓", op: true},
+	"le": {text: "≤", op: true}, "leq": {text: "≤", op: true}, "ge": {text: "≥", op: true}, "geq": {text: "≥", op: true},
+	"ne": {text: "≠", op: true}, "neq": {text: "≠", op: true}, "approx": {text: "≈", op: true}, "equiv": {text: "≡", op: true},
+	"to": {text: "→", op: true}, "rightarrow": {text: "→", op: true}, "leftarrow": {text: "←", op: true},
+	"Rightarrow": {text: "⇒", op: true}, "Leftrightarrow": {text: "⇔", op: true},
+	"in": {text: "∈", op: true}, "notin": {text: "∉", op: true}, "subset": {text: "⊂", op: true},
+	"cup": {text: "∪", op: true}, "cap": {text: "∩", op: true},
+
+	"infty": {text: "∞"}, "partial": {text: "∂"}, "nabla": {text: "∇"},
+	"sum": {text: "∑"}, "prod": {text: "∏"}, "int": {text: "∫"},
+	"forall": {text: "∀"}, "exists": {text: "∃"}, "ldots": {text: "…"}, "cdots": {text: "⋯"},
+
+	",": {text: " "}, ";": {text: " "}, " ": {text: " "}, "quad": {text: " "},
+	"{": {text: "{"}, "}": {text: "}"}, "$": {text: "$"}, "%": {text: "%"}, "#": {text: "#"},
+}
+
+/* mathOperators are the characters spaced apart as binary operators and relations, `-` is set as a minus */
+var mathOperators = map[rune]string{'+': "+", '-': "−", '=': "=", '<': "<", '>': ">"}
+
+type mathParser struct {
+	src string
+	pos int
+}
+
+/* parseMath parses the TeX-subset of `src`: symbols, `^`, `_`, `\frac`, `\sqrt`, `\text` and `{}`-groups */
+func parseMath(src string) (mathRow, error) {
+	p := mathParser{src: src}
+	return p.row(false)
+}
+
+func (p *mathParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+/* row parses up to the end of `src`, or the closing `}` of a group */
+func (p *mathParser) row(group bool) (mathRow, error) {
+	var row mathRow
+	for {
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			if group {
+				return nil, errors.New("missing `}`")
+			}
+			return row, nil
+		}
+		switch c := p.src[p.pos]; c {
+		case '}':
+			if !group {
+				return nil, errors.New("unbalanced `}`")
+			}
+			p.pos++
+			return row, nil
+		case '^', '_':
+			p.pos++
+			arg, err := p.atom()
+			if err != nil {
+				return nil, err
+			}
+			var scripts mathScripts
+			if n := len(row); n > 0 {
+				if prev, ok := row[n-1].(mathScripts); ok {
+					scripts = prev
+				} else {
+					scripts.base = row[n-1]
+				}
+				row = row[:n-1]
+			}
+			slot := &scripts.sup
+			if c == '_' {
+				slot = &scripts.sub
+			}
+			if *slot != nil {
+				return nil, fmt.Errorf("double `%c`", c)
+			}
+			*slot = arg
+			row = append(row, scripts)
+		default:
+			node, err := p.atom()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, node)
+		}
+	}
+}
+
+/* atom parses a single character, command or group */
+func (p *mathParser) atom() (mathNode, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, errors.New("missing argument")
+	}
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	switch {
+	case r == '{':
+		return p.row(true)
+	case r == '\\':
+		return p.command()
+	case r == '}' || r == '^' || r == '_':
+		return nil, fmt.Errorf("unexpected `%c`", r)
+	case unicode.IsLetter(r):
+		return mathText{text: string(r), italic: true}, nil
+	}
+	if op, ok := mathOperators[r]; ok {
+		return mathText{text: op, op: true}, nil
+	}
+	return mathText{text: string(r)}, nil
+}
+
+/* command parses the command after a `\` */
+func (p *mathParser) command() (mathNode, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z') {
+		p.pos++
+	}
+	if p.pos == start && p.pos < len(p.src) {
+		/* a single non-letter, like `\,` or `\{` */
+		p.pos++
+	}
+	name := p.src[start:p.pos]
+	switch name {
+	case "frac":
+		num, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		return mathFrac{num, den}, nil
+	case "sqrt":
+		body, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		return mathSqrt{body}, nil
+	case "text", "mathrm":
+		p.skipSpace()
+		if !strings.HasPrefix(p.src[p.pos:], "{") {
+			return nil, fmt.Errorf("`\\%s` requires a group", name)
+		}
+		text, _, ok := strings.Cut(p.src[p.pos+1:], "}")
+		if !ok {
+			return nil, errors.New("missing `}`")
+		}
+		p.pos += len(text) + 2
+		return mathText{text: text}, nil
+	}
+	if sym, ok := mathSymbols[name]; ok {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("unsupported `\\%s`", name)
+}
+
+/* mathGlyph is a glyph of a mathBox at its own size */
+type mathGlyph struct {
+	dot  fixed.Point26_6 /* relative to the baseline at the start of the box */
+	r    rune
+	attr MarkupAttribute
+	size float64
+}
+
+/* mathBox is a laid out math-expression, extending `ascent` above and `descent` below its baseline */
+type mathBox struct {
+	width, ascent, descent fixed.Int26_6
+	glyphs                 []mathGlyph
+	rules                  []fixed.Rectangle26_6 /* fraction-bars and radical-overlines */
+}
+
+/* add places `child` at `x` along the baseline, `dy` px lower */
+func (b *mathBox) add(child mathBox, x, dy fixed.Int26_6) {
+	off := fixed.Point26_6{X: x, Y: dy}
+	for _, g := range child.glyphs {
+		g.dot = g.dot.Add(off)
+		b.glyphs = append(b.glyphs, g)
+	}
+	for _, r := range child.rules {
+		b.rules = append(b.rules, r.Add(off))
+	}
+	b.ascent = max(b.ascent, child.ascent-dy)
+	b.descent = max(b.descent, child.descent+dy)
+}
+
+/* mathKey identifies the layout of an expression in the face of `attr` */
+type mathKey struct {
+	src         string
+	attr        MarkupAttribute
+	face        faceKey
+	fonts, mono FontCollection /* for italic variables and the verbatim fallback */
+}
+
+/* mathCache memoizes parsed expressions by their source, and their layouts */
+var mathCache = struct {
+	sync.Mutex
+	parsed map[string]parsedMath
+	boxes  map[mathKey]mathBox
+}{parsed: make(map[string]parsedMath), boxes: make(map[mathKey]mathBox)}
+
+type parsedMath struct {
+	row mathRow
+	err error
+}
+
+/* maxMathBoxes bounds mathCache.boxes, every size tried while fitting adds an entry */
+const maxMathBoxes = 1024
+
+/* cachedMath parses `src` once, later calls return the same result */
+func cachedMath(src string) (mathRow, error) {
+	mathCache.Lock()
+	defer mathCache.Unlock()
+	parsed, ok := mathCache.parsed[src]
+	if !ok {
+		parsed.row, parsed.err = parseMath(src)
+		mathCache.parsed[src] = parsed
+	}
+	return parsed.row, parsed.err
+}
+
+/* mathBox lays out the math-expression `src`, unsupported expressions are set verbatim in monospace */
+func (a MarkupAttribute) mathBox(src string, size float64, cfg PresConfig) mathBox {
+	a &^= Math
+	key := mathKey{src, a, a.cachedFace(size, cfg).key, cfg.Fonts, cfg.MonoFonts}
+	mathCache.Lock()
+	box, ok := mathCache.boxes[key]
+	mathCache.Unlock()
+	if ok {
+		return box
+	}
+	if row, err := cachedMath(src); err != nil {
+		/* reported while parsing */
+		box = layoutMath(mathText{text: src}, a|Code, size, cfg)
+	} else {
+		box = layoutMath(row, a, size, cfg)
+	}
+	mathCache.Lock()
+	if len(mathCache.boxes) >= maxMathBoxes {
+		clear(mathCache.boxes)
+	}
+	mathCache.boxes[key] = box
+	mathCache.Unlock()
+	return box
+}
+
+/* layoutMath lays out `node` in the face of `a` at `size` */
+func layoutMath(node mathNode, a MarkupAttribute, size float64, cfg PresConfig) (box mathBox) {
+	em := fixed.Int26_6(cfg.pixels(a.faceSize(size, cfg)) * 64)
+	thickness := max(em/18, fixed.I(1))
+	switch n := node.(type) {
+	case mathText:
+		if n.italic {
+			a |= Italic
+		}
+		face := a.cachedFace(size, cfg)
+		met := face.Metrics()
+		box.ascent, box.descent = met.Ascent, met.Descent
+		if n.op {
+			box.width = em * 4 / 18
+		}
+		for _, r := range n.text {
+			box.glyphs = append(box.glyphs, mathGlyph{dot: fixed.Point26_6{X: box.width}, r: r, attr: a, size: size})
+			adv, _ := face.GlyphAdvance(r)
+			box.width += adv
+		}
+		if n.op {
+			box.width += em * 4 / 18
+		}
+	case mathRow:
+		for i, child := range n {
+			/* an operator without a left operand, like a leading minus, is not spaced apart */
+			if t, ok := child.(mathText); ok && t.op && (i == 0 || isMathOp(n[i-1])) {
+				t.op = false
+				child = t
+			}
+			c := layoutMath(child, a, size, cfg)
+			box.add(c, box.width, 0)
+			box.width += c.width
+		}
+	case mathScripts:
+		var base mathBox
+		if n.base != nil {
+			base = layoutMath(n.base, a, size, cfg)
+			box.add(base, 0, 0)
+		}
+		box.width = base.width
+		if n.sup != nil {
+			sup := layoutMath(n.sup, a, size*0.7, cfg)
+			/* clear the slant of an italic base */
+			box.add(sup, base.width+em/20, -max(em*2/5, base.ascent-sup.ascent*4/5))
+			box.width = max(box.width, base.width+em/20+sup.width)
+		}
+		if n.sub != nil {
+			sub := layoutMath(n.sub, a, size*0.7, cfg)
+			box.add(sub, base.width, max(em/5, base.descent))
+			box.width = max(box.width, base.width+sub.width)
+		}
+	case mathFrac:
+		num := layoutMath(n.num, a, size*0.8, cfg)
+		den := layoutMath(n.den, a, size*0.8, cfg)
+		/* the bar sits on the math-axis, half the x-height above the baseline */
+		axis := xHeightOf(a.cachedFace(size, cfg).Metrics()) / 2
+		gap, pad := em/10, em/10
+		box.width = max(num.width, den.width) + 2*pad
+		box.add(num, (box.width-num.width)/2, -axis-thickness/2-gap-num.descent)
+		box.add(den, (box.width-den.width)/2, -axis+thickness/2+gap+den.ascent)
+		box.rules = append(box.rules, fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: pad / 2, Y: -axis - thickness/2},
+			Max: fixed.Point26_6{X: box.width - pad/2, Y: -axis - thickness/2 + thickness},
+		})
+	case mathSqrt:
+		body := layoutMath(n.body, a, size, cfg)
+		gap := em / 10
+		top := body.ascent + gap + thickness
+		/* scale the radical-sign to reach the overline */
+		bounds, _, ok := a.face(size, cfg).GlyphBounds('√')
+		scale := 1.0
+		if ok && bounds.Min.Y < 0 {
+			scale = float64(top) / float64(-bounds.Min.Y)
+		}
+		rad := layoutMath(mathText{text: "√"}, a, size*scale, cfg)
+		box.add(rad, 0, 0)
+		start := fixed.Int26_6(float64(bounds.Max.X)*scale) - thickness/2
+		box.add(body, start+gap, 0)
+		box.width = start + gap + body.width + gap
+		box.ascent = max(box.ascent, top)
+		box.rules = append(box.rules, fixed.Rectangle26_6{
+			Min: fixed.Point26_6{X: start, Y: -top},
+			Max: fixed.Point26_6{X: box.width, Y: -top + thickness},
+		})
+	}
+	return box
+}
+
+/* isMathOp reports whether `node` is a spaced operator */
+func isMathOp(node mathNode) bool {
+	t, ok := node.(mathText)
+	return ok && t.op
+}
+
+/* draw draws the box with its baseline starting at `dot` */
+func (b *mathBox) draw(img draw.Image, origin image.Point, dot fixed.Point26_6, outline int, fill image.Image, cfg PresConfig) {
+	type sizedFace struct {
+		attr MarkupAttribute
+		size float64
+	}
+	faces := make(map[sizedFace]font.Face)
+	for _, g := range b.glyphs {
+		key := sizedFace{g.attr, g.size}
+		face, ok := faces[key]
+		if !ok {
+			face = g.attr.face(g.size, cfg)
+			faces[key] = face
+		}
+		dr, mask, maskp, _, ok := face.Glyph(dot.Add(g.dot), g.r)
+		if ok {
+			drawGlyph(img, dr.Add(origin), mask, maskp, outline, fill, cfg)
+		}
+	}
+	for _, r := range b.rules {
+		r = r.Add(dot)
+		rect := image.Rect(r.Min.X.Round(), r.Min.Y.Round(), r.Max.X.Round(), max(r.Max.Y.Round(), r.Min.Y.Round()+1)).Add(origin)
+		draw.Draw(img, rect, fill, rect.Min, draw.Over)
+	}
+}
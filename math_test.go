@@ -0,0 +1,88 @@
+package slab
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMath(t *testing.T) {
+	x := mathText{text: "x", italic: true}
+	tests := []struct {
+		src  string
+		want mathRow
+	}{
+		{`x + 1`, mathRow{x, mathText{text: "+", op: true}, mathText{text: "1"}}},
+		{`\frac{x}{2}`, mathRow{mathFrac{mathRow{x}, mathRow{mathText{text: "2"}}}}},
+		{`\sqrt x`, mathRow{mathSqrt{x}}},
+		{`\sqrt{x^2}`, mathRow{mathSqrt{mathRow{mathScripts{base: x, sup: mathText{text: "2"}}}}}},
+		{`x_1^2`, mathRow{mathScripts{base: x, sup: mathText{text: "2"}, sub: mathText{text: "1"}}}},
+		{`^2`, mathRow{mathScripts{sup: mathText{text: "2"}}}},
+		{`\alpha \le \text{max}`, mathRow{mathText{text: "α"}, mathText{text: "≤", op: true}, mathText{text: "max"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseMath(tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+
+	for _, src := range []string{`\foo`, `x^a^b`, `x_a_b`, `{x`, `x}`, `x^`, `\frac{a}`, `\text x`, `\text{x`} {
+		if _, err := parseMath(src); err == nil {
+			t.Errorf("%q is no error", src)
+		}
+	}
+}
+
+func TestLayoutMath(t *testing.T) {
+	cfg := defaultConf()
+	const size = 40
+	plain := layoutMath(mathText{text: "x", italic: true}, 0, size, cfg)
+
+	frac := Markup{}.Attr.mathBox(`\frac{x}{x}`, size, cfg)
+	if len(frac.glyphs) != 2 || len(frac.rules) != 1 {
+		t.Fatalf(`\frac: %d glyphs and %d rules, want 2 and 1`, len(frac.glyphs), len(frac.rules))
+	}
+	num, den, bar := frac.glyphs[0], frac.glyphs[1], frac.rules[0]
+	if num.dot.Y >= bar.Min.Y || den.dot.Y <= bar.Max.Y {
+		t.Errorf(`\frac: numerator at %v and denominator at %v around the bar at %v`, num.dot.Y, den.dot.Y, bar)
+	}
+	if num.size >= size || frac.width < plain.width {
+		t.Errorf(`\frac: numerator at size %v, width %v`, num.size, frac.width)
+	}
+
+	sqrt := Markup{}.Attr.mathBox(`\sqrt{x}`, size, cfg)
+	if len(sqrt.rules) != 1 || sqrt.ascent <= plain.ascent || sqrt.width <= plain.width {
+		t.Errorf(`\sqrt: %d rules, ascent %v and width %v, body %v and %v`, len(sqrt.rules), sqrt.ascent, sqrt.width, plain.ascent, plain.width)
+	}
+	if overline := sqrt.rules[0]; overline.Max.Y > -plain.ascent {
+		t.Errorf(`\sqrt: overline at %v crosses the body`, overline)
+	}
+
+	scripts := Markup{}.Attr.mathBox(`x_1^2`, size, cfg)
+	if len(scripts.glyphs) != 3 {
+		t.Fatalf("scripts: %d glyphs, want 3", len(scripts.glyphs))
+	}
+	sup, sub := scripts.glyphs[1], scripts.glyphs[2]
+	if sup.dot.Y >= 0 || sub.dot.Y <= 0 || sup.size >= size || sub.size >= size {
+		t.Errorf("scripts: superscript at %v (size %v), subscript at %v (size %v)", sup.dot.Y, sup.size, sub.dot.Y, sub.size)
+	}
+	if sup.dot.X < plain.width || sub.dot.X < plain.width {
+		t.Errorf("scripts overlap the base of width %v", plain.width)
+	}
+
+	verbatim := Markup{}.Attr.mathBox(`x^a^b`, size, cfg)
+	var text []rune
+	for _, g := range verbatim.glyphs {
+		if !g.attr.has(Code) || g.dot.Y != 0 {
+			t.Errorf("verbatim: %q is no monospace on the baseline", g.r)
+		}
+		text = append(text, g.r)
+	}
+	if string(text) != `x^a^b` {
+		t.Errorf("verbatim: %q", string(text))
+	}
+}
@@ -0,0 +1,44 @@
+package slab
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+/* OverviewGrid returns the amount of columns and rows used to lay out `n` thumbnails */
+func OverviewGrid(n int) (cols, rows int) {
+	if n <= 0 {
+		return 0, 0
+	}
+	cols = int(math.Ceil(math.Sqrt(float64(n))))
+	rows = (n + cols - 1) / cols
+	return
+}
+
+/* DrawOverview draws a grid of thumbnails of all slides and highlights slide `selected` */
+func DrawOverview(img draw.Image, bounds image.Rectangle, pres *Presentation, selected int) {
+	bg := image.NewUniform(color.Gray{50})
+	hl := image.NewUniform(color.RGBA{230, 160, 0, 255})
+
+	draw.Draw(img, bounds, bg, image.Point{}, draw.Src)
+
+	cols, rows := OverviewGrid(len(pres.Slides))
+	if cols == 0 {
+		return
+	}
+	cw, ch := bounds.Dx()/cols, bounds.Dy()/rows
+	gap := max(min(cw, ch)/20, 2)
+	for i := range pres.Slides {
+		cell := image.Rect(0, 0, cw, ch).Add(bounds.Min).Add(image.Pt(i%cols*cw, i/cols*ch)).Inset(gap)
+		if cell.Empty() {
+			continue
+		}
+		if i == selected {
+			draw.Draw(img, cell.Inset(-gap/2), hl, image.Point{}, draw.Src)
+		}
+		thumb := RenderSlide(&pres.Slides[i], cell.Dx(), cell.Dy())
+		draw.Draw(img, cell, thumb, image.Point{}, draw.Src)
+	}
+}
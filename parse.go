@@ -2,60 +2,868 @@ package slab
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/draw"
+	"image/png"
 	"io"
+	"math"
+	"math/rand/v2"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
 type Presentation struct {
-	Conf   PresConfig
-	Slides []Slide
+	Conf        PresConfig
+	Slides      []Slide
+	Labels      map[string]int      /* slide-index by `%label` */
+	Templates   map[string]Template /* by `%define`-name */
+	Diagnostics []Diagnostic        /* warnings reported while parsing */
+}
+
+/* Diagnostic is a warning about a line of the presentation */
+type Diagnostic struct {
+	Line int
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: %s", d.Line, d.Msg)
+}
+
+/* Template is a `%define`-block, applied to a slide by `%template` */
+type Template struct {
+	Attrs   []string       /* slide-options, applied in order */
+	Content []SlideContent /* prepended to the content of the slide */
+}
+
+/* Goto resolves `target`, either a label or a slide-number as shown by `{{slide}}`, to a slide-index */
+func (p *Presentation) Goto(target string) (int, bool) {
+	if index, ok := p.Labels[target]; ok {
+		return index, true
+	}
+	n, err := strconv.Atoi(target)
+	if err != nil {
+		return 0, false
+	}
+	for i, s := range p.Slides {
+		if !s.Unnumbered && s.Number == n {
+			return i, true
+		}
+	}
+	return 0, false
 }
 
 type Slide struct {
-	Conf    PresConfig
-	Notes   string
-	Content []SlideContent
+	Conf       PresConfig
+	Notes      string
+	Content    []SlideContent
+	Rows       []int        /* indices into Content starting a new row */
+	Footnotes  []string     /* footnote-texts, numbered from 1 */
+	BlockConf  []PresConfig /* per content-block config, Conf if absent */
+	Audio      string       /* path of a WAV-file played while shown, only used by the viewer */
+	Fragments  []Fragment   /* starts of the parts revealed one step at a time */
+	Line       int          /* line in its file the slide starts at */
+	Unnumbered bool         /* not counted in `{{slide}}` and `{{total}}`, set by `%no-number` */
+	Overfull   bool         /* text does not fit even at the smallest font-size, reported as Diagnostic */
+	Number     int          /* position among the numbered slides starting at 1, set once parsed */
+	Total      int          /* amount of numbered slides in the presentation, set once parsed */
+
+	uniform []float64 /* font-size per content-block in px per 100px slide-diagonal, set by FitUniform */
+}
+
+/* Fragment is where a part of the slide revealed on its own step begins */
+type Fragment struct {
+	Block int /* index into Content */
+	Part  int /* index into the MarkupText of Block, 0 for the whole block */
+}
+
+/* Steps returns the amount of steps revealing the fragments of the slide, at least 1 */
+func (s *Slide) Steps() int {
+	return len(s.Fragments) + 1
+}
+
+/* blockConf returns the config for content-block `i` */
+func (s *Slide) blockConf(i int) PresConfig {
+	if i < len(s.BlockConf) {
+		return s.BlockConf[i]
+	}
+	return s.Conf
+}
+
+/* rows splits the content into its rows of columns */
+func (s *Slide) rows() [][]SlideContent {
+	var rows [][]SlideContent
+	start := 0
+	for _, end := range s.Rows {
+		rows = append(rows, s.Content[start:end])
+		start = end
+	}
+	return append(rows, s.Content[start:])
+}
+
+/* trimRows drops a row starting after the last of `content`, left by a `===` not followed by any cells */
+func trimRows(rows []int, content []SlideContent) []int {
+	if len(rows) > 0 && rows[len(rows)-1] == len(content) {
+		return rows[:len(rows)-1]
+	}
+	return rows
+}
+
+/* frame applies the margins to `bounds` and splits off the header- and footer-band, if any */
+func (s *Slide) frame(bounds image.Rectangle) (content, header, footer image.Rectangle) {
+	content = s.Conf.Margin.Apply(bounds)
+	h := content.Dy() / 16
+	if s.Conf.Header != "" {
+		header = content
+		header.Max.Y = content.Min.Y + h
+		content.Min.Y = header.Max.Y
+	}
+	if s.Conf.Footer != "" {
+		footer = content
+		footer.Min.Y = content.Max.Y - h
+		content.Max.Y = footer.Min.Y
+	}
+	return
+}
+
+/* expand replaces the `{{slide}}`, `{{total}}` and `{{date}}`-variables in `str`, `{{slide}}` is empty on unnumbered slides */
+func (s *Slide) expand(str string) string {
+	number := strconv.Itoa(s.Number)
+	if s.Unnumbered {
+		number = ""
+	}
+	return strings.NewReplacer(
+		"{{slide}}", number,
+		"{{total}}", strconv.Itoa(s.Total),
+		"{{date}}", time.Now().Format(time.DateOnly),
+	).Replace(str)
+}
+
+/* drawProgress draws the progress-bar along the bottom of `bounds`, if enabled */
+func (s *Slide) drawProgress(img draw.Image, bounds image.Rectangle) {
+	if !s.Conf.ProgressBar || s.Total == 0 {
+		return
+	}
+	bar := bounds
+	bar.Min.Y = bounds.Max.Y - max(bounds.Dy()/150, 2)
+	bar.Max.X = bounds.Min.X + bounds.Dx()*s.Number/s.Total
+	draw.Draw(img, bar, s.Conf.ProgressColor, image.Point{}, draw.Over)
+}
+
+/* drawBand draws the markup of a header or footer into `band` */
+func (s *Slide) drawBand(img draw.Image, band image.Rectangle, str string) {
+	var text MarkupBuilder
+	text.Feed(s.expand(str))
+	cfg := s.Conf
+	cfg.VAlign = Middle
+	cfg.FontSize = 0
+	cfg.MinFontSize, cfg.MaxFontSize = 0, 0
+	cfg.Padding = Margins{}
+	cfg.DropCap = 0
+	text.Text().Draw(img, band, cfg)
+}
+
+/* footnoteBand splits the band for footnotes off the bottom of `bounds` */
+func (s *Slide) footnoteBand(bounds image.Rectangle) (content, band image.Rectangle) {
+	band = bounds
+	band.Min.Y = bounds.Max.Y - min(bounds.Dy()*len(s.Footnotes)/20, bounds.Dy()/3)
+	bounds.Max.Y = band.Min.Y
+	return bounds, band
+}
+
+/* blockBounds returns the rectangle of every content-block when the slide fills `bounds` */
+func (s *Slide) blockBounds(bounds image.Rectangle) []image.Rectangle {
+	bounds, _, _ = s.frame(bounds)
+	if len(s.Footnotes) > 0 {
+		bounds, _ = s.footnoteBand(bounds)
+	}
+	var rects []image.Rectangle
+	rows := s.rows()
+	dh := bounds.Dy() / len(rows)
+	for r, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		y0, y1 := bounds.Min.Y+r*dh, bounds.Min.Y+(r+1)*dh
+		dw := bounds.Dx() / len(row)
+		for i := range row {
+			rects = append(rects, image.Rect(bounds.Min.X+i*dw, y0, bounds.Min.X+(i+1)*dw, y1))
+		}
+	}
+	return rects
 }
 
 func (s *Slide) Draw(img draw.Image, bounds image.Rectangle) {
-	draw.Draw(img, bounds, s.Conf.Background, image.Point{}, draw.Src)
+	s.DrawContext(context.Background(), img, bounds)
+}
+
+/* DrawContext is Draw, but stops between blocks and lines of text with an error once `ctx` is done */
+func (s *Slide) DrawContext(ctx context.Context, img draw.Image, bounds image.Rectangle) error {
+	return s.drawStep(ctx, img, bounds, s.Steps()-1)
+}
+
+/* DrawStep draws the slide with the fragments up to `step`, starting at 0, revealed */
+func (s *Slide) DrawStep(img draw.Image, bounds image.Rectangle, step int) {
+	s.drawStep(context.Background(), img, bounds, step)
+}
+
+func (s *Slide) drawStep(ctx context.Context, img draw.Image, bounds image.Rectangle, step int) error {
+	bg := s.Conf.Background
+	if fill, ok := bg.(spanner); ok {
+		bg = fill.span(bounds)
+	}
+	/* a transparent background leaves whatever is below the slide */
+	if u, ok := bg.(*image.Uniform); !ok || !transparent(u.C) {
+		draw.Draw(img, bounds, bg, bounds.Min, draw.Src)
+	}
+	/* on top of everything, so content never hides them */
+	defer drawWatermark(img, bounds, s.Conf)
+	defer s.drawProgress(img, bounds)
+
+	content, header, footer := s.frame(bounds)
+	if s.Conf.Header != "" {
+		s.drawBand(img, header, s.Conf.Header)
+	}
+	if s.Conf.Footer != "" {
+		s.drawBand(img, footer, s.Conf.Footer)
+	}
 
 	if len(s.Content) == 0 {
+		return nil
+	}
+	if len(s.Footnotes) > 0 {
+		_, band := s.footnoteBand(content)
+		drawFootnotes(img, band, s.Footnotes, s.Conf)
+	}
+	diag := math.Hypot(float64(bounds.Dx()), float64(bounds.Dy()))
+	for n, r := range s.blockBounds(bounds) {
+		cfg := s.blockConf(n)
+		if n < len(s.uniform) && s.uniform[n] > 0 {
+			cfg.FontSize = s.uniform[n] * diag / 100
+			cfg.FontUnit = FontPixel
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("drawing slide: %w", err)
+		}
+		text, ok := s.Content[n].(MarkupText)
+		if step >= 0 && step < len(s.Fragments) {
+			/* later blocks are hidden, the block of the fragment from its part on */
+			f := s.Fragments[step]
+			if n > f.Block || n == f.Block && (!ok || f.Part == 0) {
+				continue
+			}
+			if n == f.Block {
+				text = text.hideFrom(f.Part)
+			}
+		}
+		if ok {
+			if err := text.DrawContext(ctx, img, r, cfg); err != nil {
+				return err
+			}
+			continue
+		}
+		s.Content[n].Draw(img, r, cfg)
+	}
+	return nil
+}
+
+/* FitUniform fits the text of every `uniform-font-size`-group at width×height and lets it share the smallest size */
+func (p *Presentation) FitUniform(width, height int) {
+	bounds := image.Rect(0, 0, width, height)
+	diag := math.Hypot(float64(width), float64(height))
+	sizes := make(map[string]float64) /* smallest size per group in px per 100px diagonal */
+	for i := range p.Slides {
+		s := &p.Slides[i]
+		s.uniform = nil
+		for n, r := range s.blockBounds(bounds) {
+			cfg := s.blockConf(n)
+			text, ok := s.Content[n].(MarkupText)
+			if !ok || cfg.FontGroup == "" || cfg.FontSize != 0 {
+				continue
+			}
+			size, _ := text.findSize(cfg.Padding.Apply(r), cfg)
+			if size == 0 {
+				continue
+			}
+			size = cfg.pixels(size) / diag * 100
+			if prev, ok := sizes[cfg.FontGroup]; !ok || size < prev {
+				sizes[cfg.FontGroup] = size
+			}
+		}
+	}
+	if len(sizes) == 0 {
 		return
 	}
-	dw := bounds.Dx() / len(s.Content)
-	for i, cnt := range s.Content {
-		cnt.Draw(img, image.Rect(bounds.Min.X+i*dw, bounds.Min.Y, bounds.Min.X+(i+1)*dw, bounds.Max.Y), s.Conf)
+	for i := range p.Slides {
+		s := &p.Slides[i]
+		s.uniform = make([]float64, len(s.Content))
+		for n, cnt := range s.Content {
+			cfg := s.blockConf(n)
+			if _, ok := cnt.(MarkupText); ok && cfg.FontGroup != "" && cfg.FontSize == 0 {
+				s.uniform[n] = sizes[cfg.FontGroup]
+			}
+		}
+	}
+}
+
+/* Fits reports whether all text of the slide fits its box when drawn at width×height */
+func (s *Slide) Fits(width, height int) bool {
+	for n, r := range s.blockBounds(image.Rect(0, 0, width, height)) {
+		text, ok := s.Content[n].(MarkupText)
+		cfg := s.blockConf(n)
+		if !ok || len(text) == 0 || cfg.WritingMode == WritingVertical {
+			continue
+		}
+		size, _, overflows := text.fit(cfg.Padding.Apply(r), cfg)
+		if overflows || size == 0 {
+			return false
+		}
+		if fits, _ := text.Fits(r, size, cfg); !fits {
+			/* too tall or a word is wider than the box */
+			return false
+		}
+	}
+	return true
+}
+
+/* RenderSlide draws `s` headless into a new width×height image */
+func RenderSlide(s *Slide, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	s.Draw(img, img.Bounds())
+	return img
+}
+
+/* RenderSlideContext is RenderSlide, but gives up with a wrapped `ctx.Err()` once `ctx` is done */
+func RenderSlideContext(ctx context.Context, s *Slide, width, height int) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if err := s.DrawContext(ctx, img, img.Bounds()); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+/* RenderCache keeps slides rendered by RenderSlideContext, the zero value is ready and safe for concurrent use.
+ * A slide must not change while cached, refitted slides are rendered from a copy. */
+type RenderCache struct {
+	mu     sync.Mutex /* guards slides, not held while rendering */
+	slides map[renderKey]*renderEntry
+}
+
+/* renderKey identifies a rendered slide in a RenderCache */
+type renderKey struct {
+	slide         *Slide
+	width, height int
+}
+
+/* renderEntry is a slide rendered, or being rendered, at one size */
+type renderEntry struct {
+	done    chan struct{} /* closed once img and err are set */
+	img     *image.RGBA
+	err     error
+	pngOnce sync.Once
+	png     []byte
+	pngErr  error
+}
+
+/* Render returns `s` rendered at width×height, rendering it at most once per size. The image is shared, do not draw onto it */
+func (c *RenderCache) Render(ctx context.Context, s *Slide, width, height int) (*image.RGBA, error) {
+	e, err := c.entry(ctx, s, width, height)
+	if err != nil {
+		return nil, err
+	}
+	return e.img, nil
+}
+
+/* RenderPNG is Render, encoded as PNG at most once per size. The data is shared, do not modify it */
+func (c *RenderCache) RenderPNG(ctx context.Context, s *Slide, width, height int) ([]byte, error) {
+	e, err := c.entry(ctx, s, width, height)
+	if err != nil {
+		return nil, err
 	}
+	e.pngOnce.Do(func() {
+		var buf bytes.Buffer
+		e.pngErr = png.Encode(&buf, e.img)
+		e.png = buf.Bytes()
+	})
+	return e.png, e.pngErr
+}
+
+/* entry returns the entry of `s` at width×height, rendering it if it is the first to ask, or waiting for the one rendering it */
+func (c *RenderCache) entry(ctx context.Context, s *Slide, width, height int) (*renderEntry, error) {
+	key := renderKey{s, width, height}
+	c.mu.Lock()
+	e, ok := c.slides[key]
+	if !ok {
+		if c.slides == nil || len(c.slides) >= 64 {
+			c.slides = make(map[renderKey]*renderEntry)
+		}
+		e = &renderEntry{done: make(chan struct{})}
+		c.slides[key] = e
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		e.img, e.err = RenderSlideContext(ctx, s, width, height)
+		if e.err != nil {
+			/* a canceled render must not stick, the next one renders again */
+			c.mu.Lock()
+			if c.slides[key] == e {
+				delete(c.slides, key)
+			}
+			c.mu.Unlock()
+		}
+		close(e.done)
+	}
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("rendering slide: %w", ctx.Err())
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e, nil
+}
+
+/* Title returns the first line of text on the slide, or an empty string for slides without text */
+func (s *Slide) Title() string {
+	for _, cnt := range s.Content {
+		text, ok := cnt.(MarkupText)
+		if !ok {
+			continue
+		}
+		for line := range strings.Lines(text.String()) {
+			if line = strings.TrimSpace(line); line != "" {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+/* Search returns the indices of all slides whose text contains `query`, ignoring case */
+func (p *Presentation) Search(query string) []int {
+	if query == "" {
+		return nil
+	}
+	query = strings.ToLower(query)
+	var matches []int
+	for i, slide := range p.Slides {
+		for _, cnt := range slide.Content {
+			text, ok := cnt.(MarkupText)
+			if ok && strings.Contains(strings.ToLower(text.String()), query) {
+				matches = append(matches, i)
+				break
+			}
+		}
+	}
+	return matches
 }
 
 type SlideContent interface {
 	Draw(img draw.Image, bounds image.Rectangle, attr PresConfig)
 }
 
-func ParsePresentation(r io.Reader) (*Presentation, error) {
+/* contentFactories are the content-blocks registered by RegisterContent, by line-prefix */
+var contentFactories = struct {
+	sync.RWMutex
+	prefixes map[string]func(args string) (SlideContent, error)
+}{prefixes: make(map[string]func(args string) (SlideContent, error))}
+
+/* RegisterContent makes lines starting with `prefix`, like `%mermaid`, a content-block created by `factory`
+ * from the rest of the line. A later registration of the same prefix replaces it, built-in directives like
+ * `%set` or `%qr` take precedence. */
+func RegisterContent(prefix string, factory func(args string) (SlideContent, error)) {
+	if prefix == "" {
+		panic("slab: RegisterContent with an empty prefix")
+	}
+	contentFactories.Lock()
+	defer contentFactories.Unlock()
+	contentFactories.prefixes[prefix] = factory
+}
+
+/* lookupContent returns the factory of the longest registered prefix of `line` and the rest of the line */
+func lookupContent(line string) (factory func(args string) (SlideContent, error), prefix, args string) {
+	contentFactories.RLock()
+	defer contentFactories.RUnlock()
+	for p, f := range contentFactories.prefixes {
+		if strings.HasPrefix(line, p) && len(p) > len(prefix) {
+			factory, prefix = f, p
+		}
+	}
+	if factory == nil {
+		return nil, "", ""
+	}
+	return factory, prefix, strings.TrimSpace(line[len(prefix):])
+}
+
+/* condition is an open `%if`-block */
+type condition struct {
+	lineno  int
+	profile string
+	enabled bool
+}
+
+// ParsePresentation parses a presentation, `%if <profile>`-blocks are only included when
+// their profile is enabled in `profiles`.
+func ParsePresentation(r io.Reader, profiles ...string) (*Presentation, error) {
+	pres := &Presentation{Labels: make(map[string]int), Templates: make(map[string]Template)}
+	presconf, err := pres.parse(r, defaultConf(), profiles, false, false)
+	if err != nil {
+		return nil, err
+	}
+	pres.finish(presconf)
+	return pres, nil
+}
+
+// ParseFiles parses the files in sequence into one presentation, each file starts a new slide.
+// With `carry`, `%set`-options carry over into the following files, otherwise every file
+// starts from the defaults. With `strict`, an unknown or invalid option fails the parse instead
+// of being reported as a warning. With `lenient`, an image that cannot be opened is reported as
+// a warning and left out instead of failing the parse.
+func ParseFiles(filenames []string, carry, strict, lenient bool, profiles ...string) (*Presentation, error) {
+	pres := &Presentation{Labels: make(map[string]int), Templates: make(map[string]Template)}
+	presconf := defaultConf()
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		start := presconf
+		if !carry {
+			start = defaultConf()
+		}
+		presconf, err = pres.parse(file, start, profiles, strict, lenient)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	pres.finish(presconf)
+	return pres, nil
+}
+
+/* finish appends the final slide and fits uniform font-sizes */
+func (p *Presentation) finish(presconf PresConfig) {
+	p.assignBackgrounds(presconf)
+	if presconf.FinalSlide {
+		p.Slides = append(p.Slides, FinalSlide(presconf))
+	}
+	/* unnumbered slides keep the number of the slide before for the progress-bar */
+	number := 0
+	for i := range p.Slides {
+		if !p.Slides[i].Unnumbered {
+			number++
+		}
+		p.Slides[i].Number = number
+	}
+	for i := range p.Slides {
+		p.Slides[i].Total = number
+	}
+	p.Conf = presconf
+	/* reference-size, viewers refit to their actual size */
+	p.FitUniform(1024, 768)
+	p.warnOverfull(1024, 768)
+}
+
+/* warnOverfull reports slides with text too tall even at the smallest font-size, which is drawn overflowing */
+func (p *Presentation) warnOverfull(width, height int) {
+	bounds := image.Rect(0, 0, width, height)
+	for i := range p.Slides {
+		s := &p.Slides[i]
+		for n, r := range s.blockBounds(bounds) {
+			text, ok := s.Content[n].(MarkupText)
+			cfg := s.blockConf(n)
+			if !ok || len(text) == 0 || cfg.FontSize != 0 || cfg.WritingMode == WritingVertical {
+				continue
+			}
+			if size, _ := text.findSize(cfg.Padding.Apply(r), cfg); size == 0 {
+				d := Diagnostic{s.Line, fmt.Sprintf("text of slide %d does not fit even at the smallest font-size", i+1)}
+				p.Diagnostics = append(p.Diagnostics, d)
+				fmt.Fprintln(os.Stderr, d)
+				s.Overfull = true
+				break
+			}
+		}
+	}
+}
+
+/* assignBackgrounds gives every slide with a `bg-folder` one of its images as background */
+func (p *Presentation) assignBackgrounds(presconf PresConfig) {
+	pick := rand.IntN
+	if presconf.BgSeed != 0 {
+		pick = rand.New(rand.NewPCG(presconf.BgSeed, 0)).IntN
+	}
+	n := 0 /* slides with a background from a folder so far */
+	for i := range p.Slides {
+		s := &p.Slides[i]
+		if len(s.Conf.BgFolder) == 0 {
+			continue
+		}
+		index := n % len(s.Conf.BgFolder)
+		if s.Conf.BgOrder == BgRandom {
+			index = pick(len(s.Conf.BgFolder))
+		}
+		n++
+		s.Conf.Background = s.Conf.BgFolder[index]
+		if s.Conf.AutoForeground {
+			s.Conf.Foreground = contrastColor(s.Conf.Background)
+		}
+		for j := range s.BlockConf {
+			s.BlockConf[j].Background = s.Conf.Background
+			if s.BlockConf[j].AutoForeground {
+				s.BlockConf[j].Foreground = s.Conf.Foreground
+			}
+		}
+	}
+}
+
+/* lineControls are the prefixes giving a line its meaning, escaped by a leading `\` */
+var lineControls = []string{"%", "@", "#", "-", "+", "[^", "$$", "==="}
+
+/* unescapeLine strips the `\` escaping a line-control from `line`, ok is false for other backslashes, which are markup-escapes.
+ * `\@`, `\[^` and `\===` are markup-escapes as well and kept, `\$$` is escaped for the markup. */
+func unescapeLine(line string) (text string, ok bool) {
+	rest := line[1:]
+	for _, prefix := range lineControls {
+		if !strings.HasPrefix(rest, prefix) {
+			continue
+		}
+		switch prefix {
+		case "@", "[^", "===":
+			return line, true
+		case "$$":
+			return `\$\$` + rest[2:], true
+		}
+		return rest, true
+	}
+	return line, false
+}
+
+/* cutLineBreak cuts a trailing `\\` after whitespace from `line`, without whitespace it is an escaped backslash */
+func cutLineBreak(line string) (text string, ok bool) {
+	text, ok = strings.CutSuffix(line, `\\`)
+	if !ok || text == "" || !unicode.IsSpace(rune(text[len(text)-1])) {
+		return line, false
+	}
+	return text, true
+}
+
+/* stripComment removes a trailing comment, a `#` between whitespace, from directive-line `line`.
+ * A `#` followed by anything else, like in `#fff` or `#{{slide}}`, is kept, `\# ` is a literal `#`. */
+func stripComment(line string) string {
+	var buf strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '#':
+			buf.WriteByte('#')
+			i++
+		case line[i] == '#' && i > 0 && isBlank(line[i-1]) && (i+1 == len(line) || isBlank(line[i+1])):
+			return strings.TrimRightFunc(buf.String(), unicode.IsSpace)
+		default:
+			buf.WriteByte(line[i])
+		}
+	}
+	return buf.String()
+}
+
+/* isBlank reports whether `c` is a space or tab */
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+/* presentationOnly reports whether attribute `attr` only applies to the presentation as a whole,
+ * like the final slide, so setting it on a single slide has no effect */
+func presentationOnly(attr string) bool {
+	key, _, _ := strings.Cut(attr, "=")
+	switch key {
+	case "final-slide", "final-text", "final-fg", "final-bg":
+		return true
+	}
+	return false
+}
+
+/* parse appends the slides of `r` to the presentation, starting with `presconf`, and returns the resulting `%set`-config */
+func (p *Presentation) parse(r io.Reader, presconf PresConfig, profiles []string, strict, lenient bool) (PresConfig, error) {
 	scanner := bufio.NewScanner(r)
-	var pres Presentation
 	var markup MarkupBuilder
 
 	var slides []SlideContent
+	var rows []int
+	var fragments []Fragment
+	var blockattrs [][]string /* `%block`-attributes per content-block */
+	var pending []string      /* `%block`-attributes for the next content-block */
+	addBlock := func(cnt SlideContent) {
+		slides = append(slides, cnt)
+		blockattrs = append(blockattrs, pending)
+		pending = nil
+	}
+	blockConfs := func(slideconf PresConfig) []PresConfig {
+		confs := make([]PresConfig, len(blockattrs))
+		for i, attrs := range blockattrs {
+			confs[i] = slideconf
+			for _, attr := range attrs {
+				confs[i].AddAttribute(attr) /* already reported while parsing */
+			}
+		}
+		return confs
+	}
 	var notes strings.Builder
+	var audio string
+	unnumbered := false
+	footnotes := make(map[string]string)
 
-	var presconf = defaultConf()
 	var slideconf = presconf
+	defaults := sync.OnceValue(defaultConf) /* for `%reset` */
+
+	var defining *Template /* open `%define`-block */
+	var defname string
+	var tmplmarkup MarkupBuilder
+	fixed := 0 /* content-blocks of the slide prepended by `%template` */
+
+	var chart *Chart /* open `%chart`, collecting data-lines */
+	blanks := 0      /* consecutive blank lines so far */
+	touched := false /* the current slide has content, options or notes */
+
+	first := len(p.Slides) /* slides of earlier files */
 
+	var conds []condition
+	lineno := 0
+	start := 1 /* line the current slide starts at */
+	warn := func(format string, args ...any) {
+		d := Diagnostic{lineno, fmt.Sprintf(format, args...)}
+		p.Diagnostics = append(p.Diagnostics, d)
+		fmt.Fprintln(os.Stderr, d)
+	}
+	/* invalid reports an unknown or invalid option, which is an error in strict mode */
+	invalid := func(format string, args ...any) error {
+		if strict {
+			return fmt.Errorf("line %d: "+format, append([]any{lineno}, args...)...)
+		}
+		warn(format, args...)
+		return nil
+	}
+	/* feedLine feeds a line of text, a trailing ` \\` breaks the line within the paragraph */
+	feedLine := func(line string) {
+		text, broken := cutLineBreak(line)
+		markup.FeedLine(text, slideconf.LineJoin)
+		if broken {
+			markup.LineBreak()
+		}
+	}
 	for scanner.Scan() {
 		line := scanner.Text()
+		lineno++
 		/* strip trailin whitespaces */
 		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if strings.HasPrefix(line, "%") {
+			line = stripComment(line)
+		}
+
+		if strings.HasPrefix(line, "%if ") {
+			profile := strings.TrimSpace(line[4:])
+			conds = append(conds, condition{lineno, profile, slices.Contains(profiles, profile)})
+			continue
+		}
+		if line == "%endif" {
+			if len(conds) == 0 {
+				return presconf, fmt.Errorf("line %d: `%%endif` without `%%if`", lineno)
+			}
+			conds = conds[:len(conds)-1]
+			continue
+		}
+		if slices.ContainsFunc(conds, func(c condition) bool { return !c.enabled }) {
+			continue
+		}
+
+		if defining != nil {
+			switch {
+			case line == "%enddefine":
+				if tmplmarkup.Dirty() {
+					defining.Content = append(defining.Content, tmplmarkup.Text())
+					tmplmarkup.Reset()
+				}
+				p.Templates[defname] = *defining
+				defining = nil
+			case line == "":
+				tmplmarkup.Feed("\n")
+			case line[0] == '#':
+				/* comments are no notes of the template */
+			case line[0] == '@':
+				if tmplmarkup.Dirty() {
+					defining.Content = append(defining.Content, tmplmarkup.Text())
+					tmplmarkup.Reset()
+				}
+				slide, err := NewImageSlide(line[1:])
+				if err != nil {
+					return presconf, fmt.Errorf("line %d: %w", lineno, err)
+				}
+				defining.Content = append(defining.Content, slide)
+			case strings.HasPrefix(line, "%"):
+				line = strings.TrimLeftFunc(line[1:], unicode.IsSpace)
+				check := presconf
+				if presentationOnly(line) {
+					if err := invalid("template option `%s` only applies to the whole presentation, use `%%set`", line); err != nil {
+						return presconf, err
+					}
+					break
+				}
+				if err := check.AddAttribute(line); err != nil {
+					if err := invalid("template option `%s`: %v", line, err); err != nil {
+						return presconf, err
+					}
+					break
+				}
+				defining.Attrs = append(defining.Attrs, line)
+			default:
+				tmplmarkup.InlineMath = presconf.InlineMath
+				tmplmarkup.FeedLine(line, presconf.LineJoin)
+				if err := tmplmarkup.MathErr(); err != nil {
+					if err := invalid("%v", err); err != nil {
+						return presconf, err
+					}
+				}
+			}
+			continue
+		}
+
+		if chart != nil {
+			if pt, ok := parseChartPoint(line); ok {
+				chart.Points = append(chart.Points, pt)
+				continue
+			}
+			/* the first other line ends the chart */
+			chart = nil
+		}
+
+		if line != "" && line[0] != '#' {
+			blanks = 0
+		}
+		if line != "" && line != "---" && !strings.HasPrefix(line, "%set ") && !strings.HasPrefix(line, "%global ") && !strings.HasPrefix(line, "%define ") {
+			touched = true
+		}
+		markup.InlineMath = slideconf.InlineMath
+		factory, prefix, args := lookupContent(line)
 		switch {
 		case line == "":
-			markup.Feed("\n")
+			/* runs of blank lines make a single paragraph-break, unless configured otherwise */
+			blanks++
+			if slideconf.MaxBlankLines == 0 || blanks <= slideconf.MaxBlankLines {
+				markup.KeepLeading = !slideconf.TrimLeading
+				markup.Feed("\n")
+			}
+		case line[0] == '\\':
+			/* escaped line-control, or a markup-escape fed as is */
+			text, _ := unescapeLine(line)
+			feedLine(text)
 		case line[0] == '#':
 			/* ignore line -> comment */
 			if notes.Len() > 0 {
@@ -65,56 +873,289 @@ func ParsePresentation(r io.Reader) (*Presentation, error) {
 			continue
 		case line == "%%%":
 			if markup.Dirty() {
-				slides = append(slides, markup.Text())
+				addBlock(markup.Text())
 				markup.Reset()
 			}
-		case line == "---":
+		case line == "===":
 			if markup.Dirty() {
-				slides = append(slides, markup.Text())
+				addBlock(markup.Text())
+				markup.Reset()
+			}
+			/* ignore empty rows */
+			if len(slides) > 0 && (len(rows) == 0 || rows[len(rows)-1] != len(slides)) {
+				rows = append(rows, len(slides))
+			}
+		case line == "---":
+			if !touched {
+				/* a separator at the start of the file or right after another one, drop kept blank lines */
 				markup.Reset()
+				start = lineno + 1
+				break
+			}
+			if markup.Dirty() {
+				addBlock(markup.Text())
 			}
-			pres.Slides = append(pres.Slides, Slide{slideconf, notes.String(), slides})
+			markup.Reset()
+			p.Slides = append(p.Slides, Slide{
+				Conf:       slideconf,
+				Notes:      notes.String(),
+				Content:    slides,
+				Rows:       trimRows(rows, slides),
+				Footnotes:  resolveFootnotes(slides, footnotes),
+				BlockConf:  blockConfs(slideconf),
+				Audio:      audio,
+				Fragments:  fragments,
+				Line:       start,
+				Unnumbered: unnumbered,
+			})
+			start = lineno + 1
 			slides = nil
+			fragments = nil
+			rows = nil
+			blockattrs = nil
+			pending = nil
+			fixed = 0
 			slideconf = presconf
 			notes.Reset()
+			audio = ""
+			unnumbered = false
+			clear(footnotes)
+			touched = false
+		case strings.HasPrefix(line, "%global "):
+			line = strings.TrimLeftFunc(line[7:], unicode.IsSpace)
+			if len(p.Slides) > first || markup.Dirty() || len(slides) > 0 {
+				return presconf, fmt.Errorf("line %d: document option `%s` after the first slide, move it to the top of the file", lineno, line)
+			}
+			if err := presconf.AddAttribute(line); err != nil {
+				if err := invalid("document option `%s`: %v", line, err); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			slideconf.AddAttribute(line)
 		case strings.HasPrefix(line, "%set "):
 			line = strings.TrimLeftFunc(line[4:], unicode.IsSpace)
+			if markup.Dirty() || len(slides) > fixed {
+				return presconf, fmt.Errorf("line %d: global option `%s` after the content of slide %d, move it before the content", lineno, line, len(p.Slides)+1)
+			}
+			if strict && len(p.Slides) > first {
+				/* it would only apply to the slides following it */
+				return presconf, fmt.Errorf("line %d: global option `%s` after slide %d, use `%%global` at the top of the file", lineno, line, len(p.Slides))
+			}
 			if err := presconf.AddAttribute(line); err != nil {
-				fmt.Fprintf(os.Stderr, "option `%s`: %v\n", line, err)
+				if err := invalid("global option `%s`: %v", line, err); err != nil {
+					return presconf, err
+				}
+				break
 			}
+			/* also applies to the slide it starts */
+			slideconf.AddAttribute(line)
+		case strings.HasPrefix(line, "%define "):
+			defname = strings.TrimSpace(line[7:])
+			defining = &Template{}
+		case line == "%enddefine":
+			warn("`%%enddefine` without `%%define`")
+		case strings.HasPrefix(line, "%template "):
+			name := strings.TrimSpace(line[9:])
+			tmpl, ok := p.Templates[name]
+			if !ok {
+				if err := invalid("unknown template `%s`", name); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			if markup.Dirty() || len(slides) > fixed {
+				warn("template `%s` not at beginning of slide %d", name, len(p.Slides)+1)
+			}
+			for _, attr := range tmpl.Attrs {
+				slideconf.AddAttribute(attr) /* already reported while defining */
+			}
+			for _, cnt := range tmpl.Content {
+				if text, ok := cnt.(MarkupText); ok {
+					/* footnotes are numbered in place, keep the template intact */
+					cnt = slices.Clone(text)
+				}
+				addBlock(cnt)
+				fixed++
+			}
+		case strings.HasPrefix(line, "%label "):
+			label := strings.TrimSpace(line[6:])
+			if prev, ok := p.Labels[label]; ok && prev != len(p.Slides) {
+				warn("label `%s` already defined on slide %d", label, prev+1)
+			}
+			p.Labels[label] = len(p.Slides)
+		case strings.HasPrefix(line, "%reset "):
+			for _, key := range strings.Fields(line[6:]) {
+				if err := slideconf.ResetAttribute(key, defaults()); err != nil {
+					if err := invalid("reset `%s`: %v", key, err); err != nil {
+						return presconf, err
+					}
+				}
+			}
+		case strings.HasPrefix(line, "%audio "):
+			audio = strings.TrimSpace(line[6:])
+			if _, err := os.Stat(audio); err != nil {
+				warn("audio: %v", err)
+			}
+		case line == "%no-number":
+			unnumbered = true
+		case line == "%break":
+			markup.LineBreak()
+		case line == "%pause":
+			fragments = append(fragments, Fragment{len(slides), len(markup.Text())})
+		case len(line) > 4 && strings.HasPrefix(line, "$$") && strings.HasSuffix(line, "$$"):
+			/* display-math on a line of its own */
+			markup.BreakLine()
+			markup.FeedLine(line, LineJoinSpace)
+			markup.BreakLine()
+		case strings.HasPrefix(line, "+ "):
+			/* a list-item revealed on its own step */
+			markup.BreakLine()
+			fragments = append(fragments, Fragment{len(slides), len(markup.Text())})
+			markup.FeedLine("• "+strings.TrimLeftFunc(line[2:], unicode.IsSpace), LineJoinSpace) /* already on a line of its own */
+		case strings.HasPrefix(line, "%qr "):
 			if markup.Dirty() {
-				fmt.Fprintf(os.Stderr, "option not at beginning of slide\n")
+				addBlock(markup.Text())
+				markup.Reset()
+			}
+			/* encoded at the level in effect for this block */
+			conf := slideconf
+			for _, attr := range pending {
+				conf.AddAttribute(attr) /* already reported while parsing */
+			}
+			qr, err := NewQRCode(strings.TrimSpace(line[3:]), conf.QRLevel)
+			if err != nil {
+				if err := invalid("qr: %v", err); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			addBlock(qr)
+		case strings.HasPrefix(line, "%chart "):
+			kind, err := parseChartKind(strings.TrimSpace(line[6:]))
+			if err != nil {
+				if err := invalid("chart: %v", err); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			if markup.Dirty() {
+				addBlock(markup.Text())
+				markup.Reset()
+			}
+			chart = &Chart{Kind: kind}
+			addBlock(chart)
+		case strings.HasPrefix(line, "%block "):
+			line = strings.TrimLeftFunc(line[6:], unicode.IsSpace)
+			check := slideconf
+			if presentationOnly(line) {
+				if err := invalid("block option `%s` only applies to the whole presentation, use `%%set`", line); err != nil {
+					return presconf, err
+				}
+				break
 			}
+			if err := check.AddAttribute(line); err != nil {
+				if err := invalid("block option `%s`: %v", line, err); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			if markup.Dirty() {
+				warn("block option `%s` not at beginning of block on slide %d", line, len(p.Slides)+1)
+			}
+			pending = append(pending, line)
+		case factory != nil:
+			if markup.Dirty() {
+				addBlock(markup.Text())
+				markup.Reset()
+			}
+			cnt, err := factory(args)
+			if err != nil {
+				if err := invalid("%s: %v", prefix, err); err != nil {
+					return presconf, err
+				}
+				break
+			}
+			addBlock(cnt)
 		case strings.HasPrefix(line, "%"):
 			line = strings.TrimLeftFunc(line[1:], unicode.IsSpace)
+			if presentationOnly(line) {
+				if err := invalid("slide option `%s` only applies to the whole presentation, use `%%set`", line); err != nil {
+					return presconf, err
+				}
+				break
+			}
 			if err := slideconf.AddAttribute(line); err != nil {
-				fmt.Fprintf(os.Stderr, "option `%s`: %v\n", line, err)
+				if err := invalid("slide option `%s`: %v", line, err); err != nil {
+					return presconf, err
+				}
 			}
-			if markup.Dirty() {
-				fmt.Fprintf(os.Stderr, "option not at beginning of slide\n")
+			if markup.Dirty() || len(slides) > fixed {
+				warn("slide option `%s` not at beginning of slide %d", line, len(p.Slides)+1)
 			}
+		case isFootnoteDef(line):
+			label, text, _ := strings.Cut(line[2:], "]:")
+			footnotes[label] = strings.TrimSpace(text)
 		case line[0] == '@':
 			if markup.Dirty() {
-				slides = append(slides, markup.Text())
+				addBlock(markup.Text())
 				markup.Reset()
 			}
-			path := line[1:]
+			path, caption, hasCaption := strings.Cut(line[1:], ` "`)
 			slide, err := NewImageSlide(path)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ERR: %v\n", err)
-				os.Exit(1)
+				if !lenient {
+					return presconf, fmt.Errorf("line %d: image: %w", lineno, err)
+				}
+				warn("image: %v", err)
+				break
 			}
-			slides = append(slides, slide)
+			slide.Line = lineno
+			if hasCaption {
+				var capmarkup MarkupBuilder
+				capmarkup.Feed(strings.TrimSuffix(caption, `"`))
+				slide.Caption = capmarkup.Text()
+				if err := capmarkup.MathErr(); err != nil {
+					if err := invalid("%v", err); err != nil {
+						return presconf, err
+					}
+				}
+			}
+			addBlock(slide)
 		default:
-			markup.Feed(line)
+			feedLine(line)
+		}
+		if err := markup.MathErr(); err != nil {
+			if err := invalid("%v", err); err != nil {
+				return presconf, err
+			}
 		}
 	}
 	if markup.Dirty() {
-		slides = append(slides, markup.Text())
+		addBlock(markup.Text())
 		markup.Reset()
 	}
-	pres.Slides = append(pres.Slides, Slide{slideconf, notes.String(), slides})
-	pres.Slides = append(pres.Slides, FinalSlide(presconf))
-	pres.Conf = presconf
-	return &pres, scanner.Err()
+	/* skip the empty slide after a trailing separator, unless there would be no slide at all */
+	if touched || len(p.Slides) == 0 {
+		p.Slides = append(p.Slides, Slide{
+			Conf:       slideconf,
+			Notes:      notes.String(),
+			Content:    slides,
+			Rows:       trimRows(rows, slides),
+			Footnotes:  resolveFootnotes(slides, footnotes),
+			BlockConf:  blockConfs(slideconf),
+			Audio:      audio,
+			Fragments:  fragments,
+			Line:       start,
+			Unnumbered: unnumbered,
+		})
+	}
+	if defining != nil {
+		return presconf, fmt.Errorf("unclosed `%%define %s`", defname)
+	}
+	if len(conds) > 0 {
+		c := conds[len(conds)-1]
+		return presconf, fmt.Errorf("line %d: unclosed `%%if %s`", c.lineno, c.profile)
+	}
+	return presconf, scanner.Err()
 }
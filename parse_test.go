@@ -0,0 +1,442 @@
+package slab
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/* parseText parses `src` and returns the text of the first block of its first slide */
+func parseText(t *testing.T, src string) MarkupText {
+	t.Helper()
+	pres, err := ParsePresentation(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	if len(pres.Slides) == 0 || len(pres.Slides[0].Content) == 0 {
+		t.Fatalf("%q: no content", src)
+	}
+	text, ok := pres.Slides[0].Content[0].(MarkupText)
+	if !ok {
+		t.Fatalf("%q: first block is %T", src, pres.Slides[0].Content[0])
+	}
+	return text
+}
+
+func TestEscapedLine(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{`\# not a heading`, "# not a heading"},
+		{`\%set x`, "%set x"},
+		{`\- not a list`, "- not a list"},
+		{`\+ not a fragment`, "+ not a fragment"},
+		{`\@not-an-image.png`, "@not-an-image.png"},
+		{`\[^1]: not a footnote`, "[^1]: not a footnote"},
+		{`\$$x$$`, "$$x$$"},
+		{`\===`, "==="},
+		{`\*not italic\*`, "*not italic*"},
+		{"\\`not code\\`", "`not code`"},
+	}
+	for _, tt := range tests {
+		text := parseText(t, tt.line)
+		if got := text.String(); got != tt.want {
+			t.Errorf("%q = %q, want %q", tt.line, got, tt.want)
+		}
+		for _, part := range text {
+			if part.Attr != 0 {
+				t.Errorf("%q has attributes %v in %q", tt.line, part.Attr, part.Text)
+			}
+		}
+	}
+}
+
+func TestTrailingLineBreak(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"first \\\\\nsecond", "first\u2028second"},
+		{"path C:\\\\", `path C:\`},
+		{"path C:\\\\\nnext", `path C:\ next`},
+		{"\\# not a heading \\\\\nnext", "# not a heading\u2028next"},
+		{"\\%x\\\\", `%x\`},
+	}
+	for _, tt := range tests {
+		if got := parseText(t, tt.src).String(); got != tt.want {
+			t.Errorf("%q = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestBreakKeepsAttributes(t *testing.T) {
+	tests := []struct {
+		src  string
+		attr MarkupAttribute
+	}{
+		{"**bold\n%break\nstill bold**", Bold},
+		{"*italic \\\\\nstill italic*", Italic},
+		{"`code\n%break\nstill code`", Code},
+		{"~~struck\n%break\nstill struck~~", Strikethrough},
+	}
+	for _, tt := range tests {
+		text := parseText(t, tt.src)
+		found := false
+		for _, part := range text {
+			if strings.Contains(part.Text, "still") {
+				found = true
+				if !part.Attr.has(tt.attr) {
+					t.Errorf("%q: %q has attributes %v, want %v", tt.src, part.Text, part.Attr, tt.attr)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: no text after the break in %q", tt.src, text.String())
+		}
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"%fg=red # the text", "%fg=red"},
+		{"%fg=red\t#\tthe text", "%fg=red"},
+		{"%fg=red #", "%fg=red"},
+		{"%header=Slide #{{slide}}", "%header=Slide #{{slide}}"},
+		{"%fg-gradient=#fff, #000", "%fg-gradient=#fff, #000"},
+		{"%fg=#fff # white", "%fg=#fff"},
+		{`%footer=number \# 1`, "%footer=number # 1"},
+		{"%footer=a#b", "%footer=a#b"},
+	}
+	for _, tt := range tests {
+		if got := stripComment(tt.line); got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMissingImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slides.slab")
+	if err := os.WriteFile(path, []byte("text\n---\n@missing.png\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFiles([]string{path}, false, false, false); err == nil {
+		t.Error("missing image is no error")
+	}
+	pres, err := ParseFiles([]string{path}, false, false, true)
+	if err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if len(pres.Diagnostics) != 1 || pres.Diagnostics[0].Line != 3 {
+		t.Errorf("lenient: diagnostics %v, want one at line 3", pres.Diagnostics)
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	pres, err := ParsePresentation(strings.NewReader("# cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cache RenderCache
+	ctx := context.Background()
+	first, err := cache.Render(ctx, &pres.Slides[0], 320, 240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := cache.Render(ctx, &pres.Slides[0], 320, 240)
+	if again != first {
+		t.Error("same size is rendered again")
+	}
+	other, _ := cache.Render(ctx, &pres.Slides[0], 640, 480)
+	if other == first || other.Bounds().Dx() != 640 {
+		t.Errorf("other size gives %v", other.Bounds())
+	}
+
+	data, err := cache.RenderPNG(ctx, &pres.Slides[0], 320, 240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := cache.RenderPNG(ctx, &pres.Slides[0], 320, 240); &again[0] != &data[0] {
+		t.Error("same size is encoded again")
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(data)); err != nil || cfg.Width != 320 || cfg.Height != 240 {
+		t.Errorf("PNG of %d×%d: %v", cfg.Width, cfg.Height, err)
+	}
+
+	/* a canceled render is not cached */
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := cache.Render(canceled, &pres.Slides[0], 100, 100); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled render gives %v", err)
+	}
+	if img, err := cache.Render(ctx, &pres.Slides[0], 100, 100); err != nil || img.Bounds().Dx() != 100 {
+		t.Errorf("render after a canceled one gives %v", err)
+	}
+}
+
+func TestRenderCacheConcurrent(t *testing.T) {
+	pres, err := ParsePresentation(strings.NewReader("# cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cache RenderCache
+	imgs := make([]*image.RGBA, 8)
+	var wg sync.WaitGroup
+	for i := range imgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			imgs[i], _ = cache.Render(context.Background(), &pres.Slides[0], 200, 150)
+		}()
+	}
+	wg.Wait()
+	for _, img := range imgs {
+		if img == nil || img != imgs[0] {
+			t.Fatal("concurrent renders of the same size give different images")
+		}
+	}
+}
+
+func TestOverfullDiagnostic(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	tests := []struct {
+		name     string
+		src      string
+		overfull []bool
+	}{
+		{"fits", "# title\n\nsome text", []bool{false}},
+		{"overfull", long, []bool{true}},
+		{"overfull in two rows", long + "\n===\n" + long, []bool{true}},
+		{"second slide", "fits\n---\n" + long, []bool{false, true}},
+		{"fixed font-size", "%font-size=12\n" + long, []bool{false}},
+	}
+	for _, tt := range tests {
+		pres, err := ParsePresentation(strings.NewReader("%set final-slide=off\n" + tt.src))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		want := 0
+		for i, overfull := range tt.overfull {
+			if i < len(pres.Slides) && pres.Slides[i].Overfull != overfull {
+				t.Errorf("%s: slide %d overfull %v, want %v", tt.name, i+1, pres.Slides[i].Overfull, overfull)
+			}
+			if overfull {
+				want++
+			}
+		}
+		if len(pres.Diagnostics) != want {
+			t.Errorf("%s: diagnostics %v, want %d", tt.name, pres.Diagnostics, want)
+		}
+	}
+}
+
+func TestBlankLines(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"single", "one\n\ntwo", "one\ntwo"},
+		{"collapsed", "one\n\n\n\ntwo", "one\ntwo"},
+		{"max 2", "%max-blank-lines=2\none\n\n\n\ntwo", "one\n\ntwo"},
+		{"all", "%max-blank-lines=all\none\n\n\n\ntwo", "one\n\n\ntwo"},
+		{"comments between", "one\n\n# note\n\ntwo", "one\ntwo"},
+		{"leading", "\n\n\none", "one"},
+		{"break-join", "%line-join=break\none\n\n\ntwo", "one\n\ntwo"},
+	}
+	for _, tt := range tests {
+		if got := parseText(t, tt.src).String(); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		slides []string /* first line of text per slide */
+		lines  []int    /* line each slide starts at, after the `%set final-slide` */
+	}{
+		{"plain", "one\n---\ntwo", []string{"one", "two"}, []int{1, 4}},
+		{"leading", "---\none\n---\ntwo", []string{"one", "two"}, []int{3, 5}},
+		{"doubled", "one\n---\n---\ntwo", []string{"one", "two"}, []int{1, 5}},
+		{"doubled with blank lines", "one\n---\n\n---\n\ntwo", []string{"one", "two"}, []int{1, 6}},
+		{"trailing", "one\n---\ntwo\n---\n", []string{"one", "two"}, []int{1, 4}},
+		{"only separators", "---\n---\n", []string{""}, []int{4}},
+		{"notes keep a slide", "one\n---\n# notes only\n---\ntwo", []string{"one", "", "two"}, []int{1, 4, 6}},
+		{"%set keeps no slide", "---\n%set fg=red\n---\none", []string{"one"}, []int{5}},
+	}
+	for _, tt := range tests {
+		pres, err := ParsePresentation(strings.NewReader("%set final-slide=off\n" + tt.src))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var titles []string
+		var lines []int
+		for _, slide := range pres.Slides {
+			titles = append(titles, slide.Title())
+			lines = append(lines, slide.Line)
+		}
+		if !slices.Equal(titles, tt.slides) {
+			t.Errorf("%s: slides %q, want %q", tt.name, titles, tt.slides)
+		}
+		if !slices.Equal(lines, tt.lines) {
+			t.Errorf("%s: starting at lines %v, want %v", tt.name, lines, tt.lines)
+		}
+	}
+}
+
+func TestTrimLeadingPerSlide(t *testing.T) {
+	pres, err := ParsePresentation(strings.NewReader("%trim-leading=off\n\n\none\n---\n\n\ntwo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"\none", "two"}
+	for i, w := range want {
+		text, _ := pres.Slides[i].Content[0].(MarkupText)
+		if got := text.String(); got != w {
+			t.Errorf("slide %d: %q, want %q", i+1, got, w)
+		}
+	}
+}
+
+func TestRowSeparators(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		rows int
+	}{
+		{"none", "one", 1},
+		{"two rows", "one\n===\ntwo", 2},
+		{"trailing", "one\n===\ntwo\n===", 2},
+		{"trailing before the next slide", "one\n===\n---\ntwo", 1},
+		{"leading", "===\none", 1},
+		{"doubled", "one\n===\n===\ntwo", 2},
+	}
+	for _, tt := range tests {
+		pres, err := ParsePresentation(strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		rows := pres.Slides[0].rows()
+		if len(rows) != tt.rows {
+			t.Errorf("%s: %d rows, want %d", tt.name, len(rows), tt.rows)
+		}
+		for i, row := range rows {
+			if len(row) == 0 {
+				t.Errorf("%s: row %d is empty", tt.name, i+1)
+			}
+		}
+	}
+}
+
+func TestPresentationOnlyOption(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+	}{
+		{"%final-slide=off\ntext", 1},
+		{"%final-text=bye\ntext", 1},
+		{"%block final-slide=off\ntext", 1},
+		{"%define tmpl\n%final-slide=off\n%enddefine\ntext", 2},
+	}
+	for _, tt := range tests {
+		pres, err := ParsePresentation(strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.src, err)
+		}
+		if len(pres.Diagnostics) != 1 || pres.Diagnostics[0].Line != tt.line {
+			t.Errorf("%q: diagnostics %v, want one at line %d", tt.src, pres.Diagnostics, tt.line)
+		}
+		if len(pres.Slides) != 2 {
+			t.Errorf("%q: %d slides, want the final slide kept", tt.src, len(pres.Slides))
+		}
+	}
+	path := filepath.Join(t.TempDir(), "slides.slab")
+	if err := os.WriteFile(path, []byte(tests[0].src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFiles([]string{path}, false, true, false); err == nil {
+		t.Error("strict: slide option `final-slide` is no error")
+	}
+}
+
+func TestMathDiagnostic(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+	}{
+		{"$$x^2$$", 0},
+		{"text\n$$\\foo$$", 2},
+		{"text with $${x$$ in it", 1},
+		{"%set inline-math=on\ntext with $x^a^b$ in it", 2},
+		{"%define tmpl\n$$x}$$\n%enddefine\ntext", 2},
+	}
+	for _, tt := range tests {
+		pres, err := ParsePresentation(strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatalf("%q: %v", tt.src, err)
+		}
+		if tt.line == 0 {
+			if len(pres.Diagnostics) != 0 {
+				t.Errorf("%q: diagnostics %v", tt.src, pres.Diagnostics)
+			}
+			continue
+		}
+		if len(pres.Diagnostics) != 1 || pres.Diagnostics[0].Line != tt.line {
+			t.Errorf("%q: diagnostics %v, want one at line %d", tt.src, pres.Diagnostics, tt.line)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "slides.slab")
+	if err := os.WriteFile(path, []byte(tests[1].src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseFiles([]string{path}, false, true, false); err == nil {
+		t.Error("strict: invalid math is no error")
+	}
+}
+
+func TestNoNumber(t *testing.T) {
+	pres, err := ParsePresentation(strings.NewReader("%set final-slide=off\n%no-number\n# Cover\n---\n# One\n---\n%label end\n# Two"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		number, total int
+		slide         string
+	}{
+		{0, 2, ""},
+		{1, 2, "1"},
+		{2, 2, "2"},
+	}
+	if len(pres.Slides) != len(tests) {
+		t.Fatalf("%d slides, want %d", len(pres.Slides), len(tests))
+	}
+	for i, tt := range tests {
+		s := &pres.Slides[i]
+		if s.Number != tt.number || s.Total != tt.total {
+			t.Errorf("slide %d: number %d of %d, want %d of %d", i, s.Number, s.Total, tt.number, tt.total)
+		}
+		if got := s.expand("{{slide}}/{{total}}"); got != tt.slide+"/2" {
+			t.Errorf("slide %d: expands to %q, want %q", i, got, tt.slide+"/2")
+		}
+	}
+
+	for target, want := range map[string]int{"1": 1, "2": 2, "end": 2} {
+		if index, ok := pres.Goto(target); !ok || index != want {
+			t.Errorf("goto %s: slide-index %d (%v), want %d", target, index, ok, want)
+		}
+	}
+	for _, target := range []string{"0", "3", "cover"} {
+		if index, ok := pres.Goto(target); ok {
+			t.Errorf("goto %s: slide-index %d, want none", target, index)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package present
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/* audio plays the `%audio`-cue of the shown slide */
+type audio struct {
+	dev     sdl.AudioDeviceID /* 0 if nothing is playing */
+	playing string
+}
+
+/* play starts the cue at `path`, stopping the previous one; an empty path only stops */
+func (a *audio) play(path string) {
+	if path == a.playing {
+		return
+	}
+	a.stop()
+	a.playing = path
+	if path == "" {
+		return
+	}
+	data, spec := sdl.LoadWAV(path)
+	if spec == nil {
+		fmt.Fprintf(os.Stderr, "audio `%s`: %v\n", path, sdl.GetError())
+		return
+	}
+	defer sdl.FreeWAV(data)
+	dev, err := sdl.OpenAudioDevice("", false, spec, nil, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audio `%s`: %v\n", path, err)
+		return
+	}
+	if err := sdl.QueueAudio(dev, data); err != nil {
+		fmt.Fprintf(os.Stderr, "audio `%s`: %v\n", path, err)
+		sdl.CloseAudioDevice(dev)
+		return
+	}
+	sdl.PauseAudioDevice(dev, false)
+	a.dev = dev
+}
+
+func (a *audio) stop() {
+	if a.dev != 0 {
+		sdl.CloseAudioDevice(a.dev)
+		a.dev = 0
+	}
+	a.playing = ""
+}
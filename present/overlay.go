@@ -0,0 +1,74 @@
+package present
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/* circle is an alpha-mask of a filled circle around `p` */
+type circle struct {
+	p image.Point
+	r int
+}
+
+func (c *circle) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (c *circle) Bounds() image.Rectangle {
+	return image.Rect(c.p.X-c.r, c.p.Y-c.r, c.p.X+c.r, c.p.Y+c.r)
+}
+
+func (c *circle) At(x, y int) color.Color {
+	xx, yy, rr := float64(x-c.p.X)+0.5, float64(y-c.p.Y)+0.5, float64(c.r)
+	if xx*xx+yy*yy < rr*rr {
+		return color.Alpha{255}
+	}
+	return color.Alpha{0}
+}
+
+/* drawDot blends a filled circle with radius `r` around `p` onto `img` */
+func drawDot(img draw.Image, p image.Point, r int, col color.Color) {
+	mask := &circle{p, r}
+	draw.DrawMask(img, mask.Bounds(), image.NewUniform(col), image.Point{}, mask, mask.Bounds().Min, draw.Over)
+}
+
+/* drawStroke draws connected line-segments through `points` with a pen of radius `r` */
+func drawStroke(img draw.Image, points []image.Point, r int, col color.Color) {
+	src := image.NewUniform(col)
+	prev := points[0]
+	for _, p := range points {
+		d := p.Sub(prev)
+		steps := max(abs(d.X), abs(d.Y), 1)
+		for i := 1; i <= steps; i++ {
+			mask := &circle{prev.Add(d.Mul(i).Div(steps)), r}
+			draw.DrawMask(img, mask.Bounds(), src, image.Point{}, mask, mask.Bounds().Min, draw.Over)
+		}
+		prev = p
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+/* crossfade blends `from` into `to` on the surface of `win` over `dur` */
+func crossfade(win *sdl.Window, surface draw.Image, from, to *image.RGBA, dur time.Duration) {
+	blend := image.NewRGBA(to.Bounds())
+	start := time.Now()
+	for t := time.Since(start); t < dur; t = time.Since(start) {
+		alpha := image.NewUniform(color.Alpha{uint8(255 * t / dur)})
+		draw.Draw(blend, blend.Bounds(), from, from.Bounds().Min, draw.Src)
+		draw.DrawMask(blend, blend.Bounds(), to, to.Bounds().Min, alpha, image.Point{}, draw.Over)
+		draw.Draw(surface, surface.Bounds(), blend, blend.Bounds().Min, draw.Src)
+		win.UpdateSurface()
+		time.Sleep(time.Second / 60)
+	}
+}
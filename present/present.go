@@ -0,0 +1,491 @@
+package present
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/friedelschoen/slab"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const helpText = `**Navigation**
+Left, Up, PageUp	previous slide
+Right, Down, PageDown	next slide
+Click, Scroll	next or previous slide
+Tap, Swipe	next or previous slide
+Tab, o, Pinch	overview
+:	go to slide number or label
+/	search, n for next match
+
+**Presenting**
+f	toggle fullscreen
+b, w	blank to black or white
+l	laser pointer
+p	pen, c to clear ink
+s	toggle presenter window
+v	cycle presenter layout
+
+?	toggle this help
+q	quit`
+
+/* touch-gestures, as fraction of the window */
+const (
+	swipeDistance = 0.15 /* horizontal movement stepping a slide */
+	tapDistance   = 0.02 /* movement still counting as a tap */
+	pinchDistance = 0.05 /* change of the distance between fingers toggling the overview */
+)
+
+/* Options configure Present, the zero value shows the presentation without remote, live view or hooks */
+type Options struct {
+	Title string /* shown in the window-titles, usually the filenames */
+	Serve string /* address of the HTTP-remote, "" for none */
+	Token string /* token required by the HTTP-remote to navigate */
+	Web   string /* address of the live view, "" for none */
+
+	OnSlideChange func(index int) /* called with the slide-index whenever the shown slide changes, may be nil */
+}
+
+/* Present shows `pres` in a window with a presenter-window next to it until the user quits */
+func Present(pres *slab.Presentation, opts Options) {
+	filename := opts.Title
+	sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO)
+	defer sdl.Quit()
+
+	var cue audio
+	defer cue.stop()
+
+	var rm *remote
+	if opts.Serve != "" {
+		rm = newRemote(pres, opts.Token)
+		rm.serve(opts.Serve)
+	}
+	var wb *web
+	if opts.Web != "" {
+		wb = newWeb(pres)
+		wb.serve(opts.Web)
+	}
+
+	size := pres.Conf.WindowSize
+	win, err := sdl.CreateWindow("slab - "+filename, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(size.X), int32(size.Y), sdl.WINDOW_SHOWN)
+	if err != nil {
+		panic(err)
+	}
+	fullscreen := false
+
+	preswin, err := sdl.CreateWindow("slab - Presenter - "+filename, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, 1000, 600, sdl.WINDOW_SHOWN)
+	if err != nil {
+		panic(err)
+	}
+
+	presShown := true
+
+	winID, err := win.GetID()
+	if err != nil {
+		panic(err)
+	}
+
+	index := 0
+	reported := -1        /* slide-index last passed to OnSlideChange */
+	var blank image.Image /* nil, or the solid color covering the main window */
+	var frame *image.RGBA /* rendered slide, overlays are composited on top */
+	shown := -1           /* slide index rendered into `frame`, -1 if none */
+	laser := false
+	pen := false
+	drawing := false
+	ink := make(map[int][][]image.Point) /* strokes per slide index */
+	overview := false
+	selected := 0
+	help := false
+	helpDismissed := false /* `?` closed the help, ignore its text-input */
+	prompt := rune(0)      /* '/' while searching, ':' while entering a goto-target */
+	var query []rune
+	var matches []int
+	match := 0
+	var mouse image.Point
+	var touchStart sdl.FPoint /* first finger down of the current touch */
+	fingers := 0              /* fingers on the screen */
+	pinch := float32(0)       /* pinched distance of the current touch */
+	pinched := false          /* the current touch toggled the overview, it is no swipe or tap */
+	/* step moves `delta` slides, reports whether the slide changed */
+	fragment := 0 /* step revealing the fragments of the shown slide */
+	step := func(delta int) bool {
+		/* single steps reveal or hide fragments before leaving the slide */
+		if delta == 1 && fragment < pres.Slides[index].Steps()-1 {
+			fragment++
+			return true
+		}
+		if delta == -1 && fragment > 0 {
+			fragment--
+			return true
+		}
+		next := max(min(index+delta, len(pres.Slides)-1), 0)
+		changed := next != index
+		index = next
+		if changed {
+			fragment = 0
+			if delta == -1 {
+				/* going back shows the previous slide as it was left */
+				fragment = pres.Slides[index].Steps() - 1
+			}
+		}
+		return changed
+	}
+	running := true
+	for running {
+		ev := sdl.WaitEvent()
+
+		dirty := false
+		refresh := false /* only re-composite overlays onto `frame` */
+		switch ev := ev.(type) {
+		case *sdl.QuitEvent:
+			running = false
+		case *sdl.WindowEvent:
+			switch ev.Event {
+			case sdl.WINDOWEVENT_CLOSE:
+				win.Destroy()
+				preswin.Destroy()
+				running = false
+			case sdl.WINDOWEVENT_RESIZED:
+				fallthrough
+			case sdl.WINDOWEVENT_EXPOSED, sdl.WINDOWEVENT_SIZE_CHANGED:
+				dirty = true
+			}
+		case *sdl.MouseMotionEvent:
+			if ev.WindowID != winID {
+				break
+			}
+			mouse = image.Pt(int(ev.X), int(ev.Y))
+			if drawing && len(ink[index]) > 0 {
+				strokes := ink[index]
+				strokes[len(strokes)-1] = append(strokes[len(strokes)-1], mouse)
+				refresh = true
+			}
+			if laser {
+				refresh = true
+			}
+		case *sdl.UserEvent:
+			if rm == nil || ev.Type != rm.event {
+				break
+			}
+			switch ev.Code {
+			case remoteNext:
+				dirty = step(1)
+			case remotePrev:
+				dirty = step(-1)
+			default:
+				dirty = step(int(ev.Code) - index)
+			}
+			if dirty {
+				overview = false
+			}
+		case *sdl.MouseWheelEvent:
+			if overview || prompt != 0 {
+				break
+			}
+			/* scrolling up goes back */
+			if ev.Y > 0 && step(-1) || ev.Y < 0 && step(1) {
+				dirty = true
+			}
+		case *sdl.TouchFingerEvent:
+			switch ev.Type {
+			case sdl.FINGERDOWN:
+				if fingers == 0 {
+					touchStart = sdl.FPoint{X: ev.X, Y: ev.Y}
+					pinch, pinched = 0, false
+				}
+				fingers++
+			case sdl.FINGERUP:
+				fingers = max(fingers-1, 0)
+				if fingers > 0 || pinched || overview || pen || prompt != 0 {
+					break
+				}
+				dx, dy := ev.X-touchStart.X, ev.Y-touchStart.Y
+				switch {
+				case dx < -swipeDistance:
+					dirty = step(1)
+				case dx > swipeDistance:
+					dirty = step(-1)
+				case math.Abs(float64(dx)) < tapDistance && math.Abs(float64(dy)) < tapDistance:
+					dirty = step(1)
+				}
+			}
+		case *sdl.MultiGestureEvent:
+			if pinched || prompt != 0 {
+				break
+			}
+			/* pinching the fingers together opens the overview, spreading them closes it */
+			pinch += ev.DDist
+			if pinch < -pinchDistance && !overview {
+				overview = true
+				selected = index
+				drawing = false
+				pinched, dirty = true, true
+			} else if pinch > pinchDistance && overview {
+				overview = false
+				pinched, dirty = true, true
+			}
+		case *sdl.MouseButtonEvent:
+			if ev.WindowID != winID || overview || prompt != 0 {
+				break
+			}
+			if !pen {
+				if ev.Type != sdl.MOUSEBUTTONDOWN || ev.Which == uint32(sdl.TOUCH_MOUSEID) {
+					/* taps are handled as touch-events */
+					break
+				}
+				switch ev.Button {
+				case sdl.BUTTON_LEFT:
+					dirty = step(1)
+				case sdl.BUTTON_RIGHT:
+					dirty = step(-1)
+				}
+				break
+			}
+			if ev.Button != sdl.BUTTON_LEFT {
+				break
+			}
+			if ev.Type == sdl.MOUSEBUTTONDOWN {
+				mouse = image.Pt(int(ev.X), int(ev.Y))
+				ink[index] = append(ink[index], []image.Point{mouse})
+				drawing = true
+				refresh = true
+			} else {
+				drawing = false
+			}
+		case *sdl.TextInputEvent:
+			text := ev.GetText()
+			if text == "?" && prompt == 0 && !overview {
+				if !helpDismissed {
+					help = true
+					refresh = true
+				}
+				helpDismissed = false
+			} else if prompt != 0 {
+				query = append(query, []rune(text)...)
+				preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - %c%s", filename, prompt, string(query)))
+			} else if (text == "/" || text == ":") && !overview {
+				prompt = rune(text[0])
+				query = query[:0]
+				preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - %c", filename, prompt))
+			}
+		case *sdl.KeyboardEvent:
+			if ev.Type != sdl.KEYDOWN {
+				break
+			}
+			if help {
+				switch ev.Keysym.Sym {
+				case sdl.K_LSHIFT, sdl.K_RSHIFT, sdl.K_LCTRL, sdl.K_RCTRL, sdl.K_LALT, sdl.K_RALT, sdl.K_LGUI, sdl.K_RGUI, sdl.K_MODE:
+					/* modifiers are part of the next key */
+				default:
+					help = false
+					helpDismissed = true
+					refresh = true
+				}
+				break
+			}
+			helpDismissed = false
+			if prompt != 0 {
+				switch ev.Keysym.Sym {
+				case sdl.K_BACKSPACE:
+					if len(query) > 0 {
+						query = query[:len(query)-1]
+					}
+					preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - %c%s", filename, prompt, string(query)))
+				case sdl.K_RETURN, sdl.K_KP_ENTER:
+					switch prompt {
+					case '/':
+						matches = pres.Search(string(query))
+						match = 0
+						preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - /%s (%d matches)", filename, string(query), len(matches)))
+						if len(matches) > 0 && matches[0] != index {
+							index, fragment = matches[0], 0
+							dirty = true
+						}
+					case ':':
+						target, ok := pres.Goto(string(query))
+						if !ok {
+							preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - unknown slide `%s`", filename, string(query)))
+							break
+						}
+						preswin.SetTitle("slab - Presenter - " + filename)
+						if target != index {
+							index, fragment = target, 0
+							dirty = true
+						}
+					}
+					prompt = 0
+				case sdl.K_ESCAPE:
+					prompt = 0
+					preswin.SetTitle("slab - Presenter - " + filename)
+				}
+				break
+			}
+			if overview {
+				cols, _ := slab.OverviewGrid(len(pres.Slides))
+				switch ev.Keysym.Sym {
+				case sdl.K_LEFT:
+					selected--
+				case sdl.K_RIGHT:
+					selected++
+				case sdl.K_UP:
+					selected -= cols
+				case sdl.K_DOWN:
+					selected += cols
+				case sdl.K_RETURN, sdl.K_KP_ENTER:
+					index, fragment = selected, 0
+					overview = false
+				case sdl.K_TAB, sdl.K_o, sdl.K_ESCAPE:
+					overview = false
+				case sdl.K_q:
+					win.Destroy()
+					preswin.Destroy()
+					running = false
+				}
+				selected = max(min(selected, len(pres.Slides)-1), 0)
+				dirty = true
+				break
+			}
+			switch ev.Keysym.Sym {
+			case sdl.K_UP, sdl.K_LEFT, sdl.K_PAGEUP:
+				dirty = step(-1)
+			case sdl.K_DOWN, sdl.K_RIGHT, sdl.K_PAGEDOWN:
+				dirty = step(1)
+
+			case sdl.K_f:
+				if fullscreen {
+					win.SetFullscreen(0)
+					fullscreen = false
+				} else {
+					win.SetFullscreen(sdl.WINDOW_FULLSCREEN_DESKTOP)
+					fullscreen = true
+				}
+			case sdl.K_b:
+				if blank == image.Black {
+					blank = nil
+				} else {
+					blank = image.Black
+				}
+				dirty = true
+			case sdl.K_w:
+				if blank == image.White {
+					blank = nil
+				} else {
+					blank = image.White
+				}
+				dirty = true
+			case sdl.K_l:
+				laser = !laser
+				if laser {
+					sdl.ShowCursor(sdl.DISABLE)
+				} else {
+					sdl.ShowCursor(sdl.ENABLE)
+				}
+				refresh = true
+			case sdl.K_p:
+				pen = !pen
+				drawing = false
+			case sdl.K_c:
+				if len(ink[index]) > 0 {
+					delete(ink, index)
+					refresh = true
+				}
+			case sdl.K_s:
+				if presShown {
+					preswin.Hide()
+				} else {
+					preswin.Show()
+				}
+				presShown = !presShown
+				dirty = true
+			case sdl.K_v:
+				pres.Conf.PresenterLayout = pres.Conf.PresenterLayout.Next()
+				dirty = true
+			case sdl.K_n:
+				if len(matches) > 0 {
+					match = (match + 1) % len(matches)
+					index, fragment = matches[match], 0
+					preswin.SetTitle(fmt.Sprintf("slab - Presenter - %s - /%s (%d/%d)", filename, string(query), match+1, len(matches)))
+					dirty = true
+				}
+			case sdl.K_TAB, sdl.K_o:
+				overview = true
+				selected = index
+				drawing = false
+				dirty = true
+			case sdl.K_q:
+				win.Destroy()
+				preswin.Destroy()
+				running = false
+			}
+		}
+		if running == false {
+			break
+		}
+		if opts.OnSlideChange != nil && index != reported {
+			opts.OnSlideChange(index)
+			reported = index
+		}
+		if rm != nil {
+			rm.current.Store(int32(index))
+		}
+		if wb != nil {
+			wb.show(index)
+		}
+		cue.play(pres.Slides[index].Audio)
+
+		if dirty || refresh {
+			img, err := win.GetSurface()
+			if err != nil {
+				panic(err)
+			}
+			if frame == nil || frame.Bounds() != img.Bounds() {
+				pres.FitUniform(img.Bounds().Dx(), img.Bounds().Dy())
+			}
+			if dirty || frame == nil || frame.Bounds() != img.Bounds() {
+				prev, prevShown := frame, shown
+				frame = image.NewRGBA(img.Bounds())
+				shown = -1
+				if overview {
+					slab.DrawOverview(frame, frame.Bounds(), pres, selected)
+				} else if blank != nil {
+					draw.Draw(frame, frame.Bounds(), blank, image.Point{}, draw.Src)
+				} else {
+					pres.Slides[index].DrawStep(frame, frame.Bounds(), fragment)
+					shown = index
+				}
+				tr := pres.Slides[index].Conf.Transition
+				if tr.Kind == slab.Fade && prevShown != -1 && shown != -1 && prevShown != shown && prev.Bounds() == frame.Bounds() {
+					crossfade(win, img, prev, frame, tr.Duration)
+				}
+			}
+			draw.Draw(img, img.Bounds(), frame, frame.Bounds().Min, draw.Src)
+			b := img.Bounds()
+			diag := math.Hypot(float64(b.Dx()), float64(b.Dy()))
+			if !overview {
+				for _, stroke := range ink[index] {
+					drawStroke(img, stroke, max(int(diag/400), 1), color.RGBA{0, 0, 200, 255})
+				}
+			}
+			if help {
+				slab.DrawHelp(img, img.Bounds(), pres, helpText)
+			}
+			if laser {
+				drawDot(img, mouse, max(int(diag/150), 2), color.RGBA{200, 0, 0, 200})
+			}
+			win.UpdateSurface()
+		}
+
+		if dirty && presShown {
+			img, err := preswin.GetSurface()
+			if err != nil {
+				panic(err)
+			}
+			slab.DrawPresenter(img, img.Bounds(), pres, index)
+			preswin.UpdateSurface()
+			dirty = false
+		}
+	}
+}
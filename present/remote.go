@@ -0,0 +1,91 @@
+package present
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/friedelschoen/slab"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/* user-event codes pushed by the remote, codes >= 0 go to that slide-index */
+const (
+	remoteNext = -1
+	remotePrev = -2
+)
+
+/* remote controls the presentation over HTTP, navigation is pushed into the event-loop */
+type remote struct {
+	event   uint32 /* registered user-event type */
+	token   string /* required for navigation, if set */
+	pres    *slab.Presentation
+	current atomic.Int32 /* slide-index shown, stored by the event-loop */
+}
+
+func newRemote(pres *slab.Presentation, token string) *remote {
+	return &remote{event: sdl.RegisterEvents(1), token: token, pres: pres}
+}
+
+/* serve starts the HTTP-server on `addr` in the background */
+func (rm *remote) serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/next", rm.navigate(func(*http.Request) (int32, bool) {
+		return remoteNext, true
+	}))
+	mux.HandleFunc("/prev", rm.navigate(func(*http.Request) (int32, bool) {
+		return remotePrev, true
+	}))
+	mux.HandleFunc("/goto/{n}", rm.navigate(func(r *http.Request) (int32, bool) {
+		index, ok := rm.pres.Goto(r.PathValue("n"))
+		return int32(index), ok
+	}))
+	mux.HandleFunc("/status", rm.status)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "remote: %v\n", err)
+		}
+	}()
+}
+
+func (rm *remote) authorized(r *http.Request) bool {
+	if rm.token == "" {
+		return true
+	}
+	token := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && auth[:7] == "Bearer " {
+		token = auth[7:]
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(rm.token)) == 1
+}
+
+/* navigate pushes the code returned by `target` into the event-loop */
+func (rm *remote) navigate(target func(*http.Request) (int32, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rm.authorized(r) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		code, ok := target(r)
+		if !ok {
+			http.Error(w, "unknown slide", http.StatusNotFound)
+			return
+		}
+		if _, err := sdl.PushEvent(&sdl.UserEvent{Type: rm.event, Code: code}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (rm *remote) status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Index int `json:"index"`
+		Total int `json:"total"`
+	}{int(rm.current.Load()), len(rm.pres.Slides)})
+}
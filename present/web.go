@@ -0,0 +1,190 @@
+package present
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/friedelschoen/slab"
+)
+
+const webPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>slab</title>
+<style>
+html, body { margin: 0; height: 100%; background: #000; }
+img { display: block; width: 100vw; height: 100vh; object-fit: contain; }
+</style>
+</head>
+<body>
+<img id="slide">
+<script>
+const slide = document.getElementById("slide");
+let index = 0;
+function show() {
+	const r = window.devicePixelRatio || 1;
+	slide.src = "/slide.png?n=" + index + "&w=" + Math.round(innerWidth * r) + "&h=" + Math.round(innerHeight * r);
+}
+function connect() {
+	const ws = new WebSocket((location.protocol == "https:" ? "wss://" : "ws://") + location.host + "/live");
+	ws.onmessage = ev => { index = parseInt(ev.data); show(); };
+	ws.onclose = () => setTimeout(connect, 1000);
+}
+window.onresize = show;
+connect();
+</script>
+</body>
+</html>
+`
+
+/* web serves the shown slide to browsers, changes are pushed over a WebSocket */
+type web struct {
+	pres *slab.Presentation
+
+	mu      sync.Mutex /* guards current and clients */
+	current int
+	clients map[chan int]struct{}
+
+	cache slab.RenderCache
+}
+
+func newWeb(pres *slab.Presentation) *web {
+	/* slides are refitted by the event-loop, render from an own copy */
+	own := *pres
+	own.Slides = slices.Clone(pres.Slides)
+	return &web{pres: &own, clients: make(map[chan int]struct{})}
+}
+
+/* serve starts the HTTP-server on `addr` in the background */
+func (wb *web) serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, webPage)
+	})
+	mux.HandleFunc("/slide.png", wb.slide)
+	mux.HandleFunc("/live", wb.live)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "web: %v\n", err)
+		}
+	}()
+}
+
+/* show notifies all connected browsers that slide `index` is shown */
+func (wb *web) show(index int) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if index == wb.current {
+		return
+	}
+	wb.current = index
+	for ch := range wb.clients {
+		select {
+		case ch <- index:
+		default: /* client is behind, it gets the next change */
+		}
+	}
+}
+
+func (wb *web) slide(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	wb.mu.Lock()
+	index := wb.current
+	wb.mu.Unlock()
+	if n, err := strconv.Atoi(query.Get("n")); err == nil && n >= 0 && n < len(wb.pres.Slides) {
+		index = n
+	}
+	width, err := strconv.Atoi(query.Get("w"))
+	if err != nil || width <= 0 {
+		width = 800
+	}
+	height, err := strconv.Atoi(query.Get("h"))
+	if err != nil || height <= 0 {
+		height = 600
+	}
+	width, height = min(width, 4096), min(height, 4096)
+
+	data, err := wb.cache.RenderPNG(r.Context(), &wb.pres.Slides[index], width, height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+/* live upgrades to a WebSocket and sends the slide-index on every change */
+func (wb *web) live(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Upgrade") != "websocket" || key == "" {
+		http.Error(w, "expected a websocket", http.StatusBadRequest)
+		return
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	hash := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(hash[:]))
+
+	ch := make(chan int, 1)
+	wb.mu.Lock()
+	wb.clients[ch] = struct{}{}
+	ch <- wb.current
+	wb.mu.Unlock()
+	defer func() {
+		wb.mu.Lock()
+		delete(wb.clients, ch)
+		wb.mu.Unlock()
+	}()
+
+	/* incoming frames are not used, reading only notices the disconnect */
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, rw)
+		close(closed)
+	}()
+	for {
+		select {
+		case index := <-ch:
+			if err := writeTextFrame(rw.Writer, strconv.Itoa(index)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+/* writeTextFrame writes an unmasked WebSocket text-frame */
+func writeTextFrame(w *bufio.Writer, text string) error {
+	w.WriteByte(0x81) /* FIN, text */
+	switch n := len(text); {
+	case n < 126:
+		w.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(126)
+		w.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		w.WriteByte(127)
+		w.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+	}
+	w.WriteString(text)
+	return w.Flush()
+}
@@ -6,41 +6,85 @@ import (
 	"image/draw"
 )
 
-func DrawPresenter(img draw.Image, bounds image.Rectangle, pres *Presentation, index int) {
-	slides := pres.Slides[index:]
+type PresenterLayout int
+
+const (
+	LayoutSplit        PresenterLayout = iota /* current on top, next and notes below */
+	LayoutNotesRight                          /* current and next on the left, notes full-height on the right */
+	LayoutCurrentLarge                        /* current full-height on the left, next and notes on the right */
+	numLayouts
+)
+
+/* Next returns the layout following `l`, wrapping around */
+func (l PresenterLayout) Next() PresenterLayout {
+	return (l + 1) % numLayouts
+}
+
+/* regions divides `bounds` in the current-, next- and notes-region */
+func (l PresenterLayout) regions(bounds image.Rectangle) (curR, nextR, noteR image.Rectangle) {
+	curR, nextR, noteR = bounds, bounds, bounds
+	switch l {
+	case LayoutNotesRight:
+		curR.Max.X -= bounds.Dx() * 2 / 5
+		curR.Max.Y -= bounds.Dy() * 2 / 5
+
+		nextR.Max.X = curR.Max.X
+		nextR.Min.Y = curR.Max.Y
 
-	curR := bounds
-	nextR := bounds
-	noteR := bounds
+		noteR.Min.X = curR.Max.X
+	case LayoutCurrentLarge:
+		curR.Max.X -= bounds.Dx() / 3
 
-	curR.Max.Y -= bounds.Dy() / 2
+		nextR.Min.X = curR.Max.X
+		nextR.Max.Y -= bounds.Dy() / 2
 
-	nextR.Max.X -= bounds.Dx() / 2
-	nextR.Min.Y += bounds.Dy() / 2
+		noteR.Min.X = curR.Max.X
+		noteR.Min.Y = nextR.Max.Y
+	default:
+		curR.Max.Y -= bounds.Dy() / 2
 
-	noteR.Min.X += bounds.Dx() / 2
-	noteR.Min.Y += bounds.Dy() / 2
+		nextR.Max.X -= bounds.Dx() / 2
+		nextR.Min.Y += bounds.Dy() / 2
+
+		noteR.Min.X += bounds.Dx() / 2
+		noteR.Min.Y += bounds.Dy() / 2
+	}
+	return
+}
+
+func DrawPresenter(img draw.Image, bounds image.Rectangle, pres *Presentation, index int) {
+	slides := pres.Slides[index:]
+
+	curR, nextR, noteR := pres.Conf.PresenterLayout.regions(bounds)
 
 	bg := image.NewUniform(color.Gray{50})
 	fg := image.NewUniform(color.Gray{200})
 
 	slides[0].Draw(img, curR)
-	if len(slides) > 1 {
-		slides[1].Draw(img, nextR)
-	} else {
-		draw.Draw(img, nextR, bg, image.Point{}, draw.Src)
+	upcoming := max(pres.Conf.PresenterNext, 1)
+	cols, rows := OverviewGrid(upcoming)
+	cw, ch := nextR.Dx()/cols, nextR.Dy()/rows
+	draw.Draw(img, nextR, bg, image.Point{}, draw.Src)
+	for i := range upcoming {
+		if i+1 >= len(slides) {
+			break
+		}
+		cell := image.Rect(0, 0, cw, ch).Add(nextR.Min).Add(image.Pt(i%cols*cw, i/cols*ch))
+		if upcoming > 1 {
+			cell = cell.Inset(max(min(cw, ch)/40, 1))
+		}
+		thumb := RenderSlide(&slides[i+1], cell.Dx(), cell.Dy())
+		draw.Draw(img, cell, thumb, image.Point{}, draw.Src)
 	}
 	if slides[0].Notes != "" {
 		notecfg := pres.Conf
 		notecfg.Foreground = fg
 		notecfg.Background = bg
-		noteslide := Slide{notecfg, "", []SlideContent{
-			MarkupText{
-				Markup{
-					Text: slides[0].Notes,
-				},
-			},
-		}}
+		notecfg.Header, notecfg.Footer = "", ""
+		notecfg.Watermark = nil
+		var notes MarkupBuilder
+		notes.Feed(slides[0].Notes)
+		noteslide := Slide{Conf: notecfg, Content: []SlideContent{notes.Text()}}
 		noteslide.Draw(img, noteR)
 	} else {
 		draw.Draw(img, noteR, bg, image.Point{}, draw.Src)
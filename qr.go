@@ -0,0 +1,469 @@
+package slab
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+)
+
+type QRLevel int
+
+const (
+	QRLow      QRLevel = iota /* ~7% recoverable */
+	QRMedium                  /* ~15% recoverable */
+	QRQuartile                /* ~25% recoverable */
+	QRHigh                    /* ~30% recoverable */
+)
+
+var ErrQRTooLong = errors.New("text too long for a QR code")
+
+/* qrEccPerBlock is the amount of error-correction codewords per block by level and version */
+var qrEccPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+/* qrBlocks is the amount of error-correction blocks by level and version */
+var qrBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+/* formatBits are the level-bits of the format-information */
+func (l QRLevel) formatBits() int {
+	return [4]int{1, 0, 3, 2}[l]
+}
+
+/* qrCode is the module-matrix of an encoded QR code */
+type qrCode struct {
+	size     int
+	modules  [][]bool /* dark modules, by y and x */
+	function [][]bool /* modules of finder-, timing-, alignment-patterns and format-information */
+}
+
+/* qrRawModules returns the amount of data-modules of `version`, including remainder-bits */
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+/* qrDataCodewords returns the amount of data-codewords of `version` at `level` */
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrEccPerBlock[level][version]*qrBlocks[level][version]
+}
+
+/* encodeQR encodes `data` in byte-mode in the smallest version holding it at `level` */
+func encodeQR(data []byte, level QRLevel) (*qrCode, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if len(data) < 1<<countBits && 4+countBits+8*len(data) <= qrDataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRTooLong
+	}
+
+	/* mode, length, data and terminator */
+	var bits []bool
+	appendBits := func(val, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, val>>i&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	capacity := qrDataCodewords(version, level) * 8
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	qr := &qrCode{size: version*4 + 17}
+	qr.modules = make([][]bool, qr.size)
+	qr.function = make([][]bool, qr.size)
+	for y := range qr.size {
+		qr.modules[y] = make([]bool, qr.size)
+		qr.function[y] = make([]bool, qr.size)
+	}
+	qr.drawPatterns(version)
+	qr.drawCodewords(qrInterleave(codewords, version, level))
+
+	/* keep the mask with the lowest penalty */
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		qr.applyMask(mask)
+		qr.drawFormat(level, mask)
+		if p := qr.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		qr.applyMask(mask) /* masks are their own inverse */
+	}
+	qr.applyMask(best)
+	qr.drawFormat(level, best)
+	return qr, nil
+}
+
+/* setFunction sets a module of a function-pattern */
+func (qr *qrCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.function[y][x] = true
+}
+
+/* drawPatterns draws the finder-, timing- and alignment-patterns and the version-information */
+func (qr *qrCode) drawPatterns(version int) {
+	for i := range qr.size {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= qr.size || y < 0 || y >= qr.size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				qr.setFunction(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	var align []int
+	if version > 1 {
+		n := version/7 + 2
+		step := (version*4 + n*2 + 1) / (n*2 - 2) * 2
+		if version == 32 {
+			step = 26
+		}
+		align = make([]int, n)
+		align[0] = 6
+		for i, pos := n-1, qr.size-7; i >= 1; i, pos = i-1, pos-step {
+			align[i] = pos
+		}
+	}
+	for i, y := range align {
+		for j, x := range align {
+			/* the corners hold finder-patterns */
+			if i == 0 && j == 0 || i == 0 && j == len(align)-1 || i == len(align)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	/* reserve the format-information, drawn once the mask is known */
+	qr.drawFormat(0, 0)
+
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := range 18 {
+			dark := bits>>i&1 != 0
+			a, b := qr.size-11+i%3, i/3
+			qr.setFunction(a, b, dark)
+			qr.setFunction(b, a, dark)
+		}
+	}
+}
+
+/* drawFormat draws both copies of the format-information of `level` and `mask` */
+func (qr *qrCode) drawFormat(level QRLevel, mask int) {
+	data := level.formatBits()<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := range 6 {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		qr.setFunction(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.size-8, true)
+}
+
+/* drawCodewords places `data` in the zigzag-order over all non-function modules */
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			/* skip the vertical timing-pattern */
+			right = 5
+		}
+		for vert := range qr.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.function[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = data[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+/* applyMask inverts the data-modules selected by `mask` */
+func (qr *qrCode) applyMask(mask int) {
+	for y := range qr.size {
+		for x := range qr.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.function[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+/* penalty scores runs, blocks and the balance of dark modules, lower is easier to scan */
+func (qr *qrCode) penalty() int {
+	score, dark := 0, 0
+	for a := range qr.size {
+		/* runs in rows and columns */
+		for _, at := range []func(i int) bool{
+			func(i int) bool { return qr.modules[a][i] },
+			func(i int) bool { return qr.modules[i][a] },
+		} {
+			run := 1
+			for i := 1; i <= qr.size; i++ {
+				if i < qr.size && at(i) == at(i-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+		}
+	}
+	for y := range qr.size {
+		for x := range qr.size {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := qr.modules[y][x]
+				if qr.modules[y-1][x] == c && qr.modules[y][x-1] == c && qr.modules[y-1][x-1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+	total := qr.size * qr.size
+	score += abs(dark*20-total*10) / total * 10
+	return score
+}
+
+/* qrInterleave splits `data` in blocks, appends their error-correction and interleaves them */
+func qrInterleave(data []byte, version int, level QRLevel) []byte {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrEccPerBlock[level][version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			/* placeholder, short blocks have one data-codeword less */
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	var result []byte
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+/* gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1 */
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+/* rsDivisor returns the Reed-Solomon generator-polynomial of `degree`, without its leading term */
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+/* rsRemainder returns the Reed-Solomon error-correction codewords of `data` */
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+/* QRCode is a slide-content drawing `Text` as QR code, encoded by NewQRCode */
+type QRCode struct {
+	Text    string
+	Level   QRLevel
+	modules [][]bool /* dark modules, by y and x */
+}
+
+/* NewQRCode encodes `text` at `level`, ErrQRTooLong if it does not fit in any version */
+func NewQRCode(text string, level QRLevel) (*QRCode, error) {
+	qr, err := encodeQR([]byte(text), level)
+	if err != nil {
+		return nil, err
+	}
+	return &QRCode{Text: text, Level: level, modules: qr.modules}, nil
+}
+
+func (q *QRCode) Draw(img draw.Image, bounds image.Rectangle, attr PresConfig) {
+	bounds = attr.Padding.Apply(bounds)
+	side := int(float64(min(bounds.Dx(), bounds.Dy())) * attr.QRSize)
+	/* whole pixels per module keep the code sharp, with a quiet zone of 4 modules */
+	modules := len(q.modules) + 8
+	scale := side / modules
+	if scale < 1 {
+		warnOnce("qr: %.20q: too small to draw", q.Text)
+		return
+	}
+	n := modules * scale
+	var r image.Rectangle
+	switch attr.Align {
+	case Left:
+		r.Min.X = bounds.Min.X
+	case Center:
+		r.Min.X = bounds.Min.X + (bounds.Dx()-n)/2
+	case Right:
+		r.Min.X = bounds.Max.X - n
+	}
+	switch attr.VAlign {
+	case Top:
+		r.Min.Y = bounds.Min.Y
+	case Middle:
+		r.Min.Y = bounds.Min.Y + (bounds.Dy()-n)/2
+	case Bottom:
+		r.Min.Y = bounds.Max.Y - n
+	}
+	r.Max = r.Min.Add(image.Pt(n, n))
+
+	draw.Draw(img, r, image.White, image.Point{}, draw.Src)
+	for y, row := range q.modules {
+		for x, dark := range row {
+			if dark {
+				m := image.Rect(x+4, y+4, x+5, y+5)
+				m.Min, m.Max = m.Min.Mul(scale).Add(r.Min), m.Max.Mul(scale).Add(r.Min)
+				draw.Draw(img, m, image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package slab
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	/* "HELLO WORLD" in alphanumeric-mode at version 1-M, from the ISO 18004 worked example */
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("error-correction %v, want %v", got, want)
+	}
+}
+
+/* qrFormatQ are the format-information strings of level Q by mask, bit 14 first */
+var qrFormatQ = [8]string{
+	"011010101011111", "011000001101000", "011111100110001", "011101000000110",
+	"010010010110100", "010000110000011", "010111011011010", "010101111101101",
+}
+
+func TestQRCodeVector(t *testing.T) {
+	qr, err := NewQRCode("HELLO WORLD", QRQuartile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := qr.modules
+	if len(m) != 21 {
+		t.Fatalf("size %d, want 21 for version 1", len(m))
+	}
+	/* the finder-pattern of the top-left corner */
+	for i := range 7 {
+		if !m[0][i] || !m[6][i] || !m[i][0] || !m[i][6] || m[1][i%5+1] || !m[i%3+2][3] {
+			t.Fatalf("no finder-pattern at %d", i)
+		}
+	}
+
+	/* the first copy of the format-information, around the top-left finder */
+	var format strings.Builder
+	at := func(x, y int) {
+		if m[y][x] {
+			format.WriteByte('1')
+		} else {
+			format.WriteByte('0')
+		}
+	}
+	for x := range 6 {
+		at(x, 8)
+	}
+	at(7, 8)
+	at(8, 8)
+	at(8, 7)
+	for y := 5; y >= 0; y-- {
+		at(8, y)
+	}
+	mask := -1
+	for i, f := range qrFormatQ {
+		if f == format.String() {
+			mask = i
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format-information %s is none of level Q", format.String())
+	}
+	/* the second copy is the same */
+	var second strings.Builder
+	for y := 20; y >= 14; y-- {
+		second.WriteByte("01"[btoi(m[y][8])])
+	}
+	for x := 13; x <= 20; x++ {
+		second.WriteByte("01"[btoi(m[8][x])])
+	}
+	if second.String() != format.String() {
+		t.Errorf("format-information %s and %s differ", format.String(), second.String())
+	}
+	if !m[13][8] {
+		t.Error("no dark module")
+	}
+
+	/* byte-mode 0100, length 11, the text and a terminator fill all 13 data-codewords of 1-Q */
+	data := append([]byte{0x40, 0xB0}, "HELLO WORLD"...)
+	for i := 2; i < len(data); i++ {
+		data[i-1] |= data[i] >> 4
+		data[i] <<= 4
+	}
+	want := append(data, rsRemainder(data, rsDivisor(13))...)
+
+	/* read the codewords back in the zigzag-order, unmasked */
+	var code qrCode
+	code.size = 21
+	code.modules = make([][]bool, 21)
+	code.function = make([][]bool, 21)
+	for y := range 21 {
+		code.modules[y] = make([]bool, 21)
+		code.function[y] = make([]bool, 21)
+	}
+	code.drawPatterns(1)
+	var got []byte
+	bit := 0
+	for right := 20; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range 21 {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = 20 - vert
+				}
+				if code.function[y][x] {
+					continue
+				}
+				if bit%8 == 0 {
+					got = append(got, 0)
+				}
+				if m[y][x] != qrMasked(mask, x, y) {
+					got[bit/8] |= 1 << (7 - bit%8)
+				}
+				bit++
+			}
+		}
+	}
+	if !bytes.Equal(got[:len(want)], want) {
+		t.Errorf("codewords\n%v, want\n%v", got[:len(want)], want)
+	}
+}
+
+/* qrMasked reports whether `mask` inverts the module at `x`,`y` */
+func qrMasked(mask, x, y int) bool {
+	return [8]bool{
+		(x+y)%2 == 0,
+		y%2 == 0,
+		x%3 == 0,
+		(x+y)%3 == 0,
+		(x/3+y/2)%2 == 0,
+		x*y%2+x*y%3 == 0,
+		(x*y%2+x*y%3)%2 == 0,
+		((x+y)%2+x*y%3)%2 == 0,
+	}[mask]
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestQRCodeTooLong(t *testing.T) {
+	if _, err := NewQRCode(strings.Repeat("x", 3000), QRHigh); !errors.Is(err, ErrQRTooLong) {
+		t.Errorf("got %v, want ErrQRTooLong", err)
+	}
+	pres, err := ParsePresentation(strings.NewReader("%qr " + strings.Repeat("x", 3000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pres.Diagnostics) != 1 || pres.Diagnostics[0].Line != 1 {
+		t.Errorf("diagnostics %v, want one at line 1", pres.Diagnostics)
+	}
+}
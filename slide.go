@@ -2,6 +2,7 @@ package slab
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -11,31 +12,43 @@ import (
 	"image/png"
 	"io"
 	"os"
+	"runtime"
+	"sync"
 
 	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
 )
 
-var formats = []struct {
-	Decode   func(io.Reader) (image.Image, error)
-	Offset   int
-	Patterns [][]int
-}{
-	{png.Decode, 0, [][]int{{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}}},
-	{jpeg.Decode, 0, [][]int{
+type imageFormat struct {
+	Name         string
+	Decode       func(io.Reader) (image.Image, error)
+	DecodeConfig func(io.Reader) (image.Config, error)
+	Offset       int
+	Patterns     [][]int
+}
+
+var formats = []imageFormat{
+	{"png", png.Decode, png.DecodeConfig, 0, [][]int{{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}}},
+	{"jpeg", jpeg.Decode, jpeg.DecodeConfig, 0, [][]int{
 		{0xFF, 0xD8, 0xFF, 0xDB},
 		{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01},
 		{0xFF, 0xD8, 0xFF, 0xEE},
 		{0xFF, 0xD8, 0xFF, 0xE1, 0x100, 0x100, 0x45, 0x78, 0x69, 0x66, 0x00, 0x00},
 		{0xFF, 0xD8, 0xFF, 0xE0},
 	}},
-	{gif.Decode, 0, [][]int{
+	{"gif", gif.Decode, gif.DecodeConfig, 0, [][]int{
 		{0x47, 0x49, 0x46, 0x38, 0x37, 0x61},
 		{0x47, 0x49, 0x46, 0x38, 0x39, 0x61},
 	}},
+	{"tiff", tiff.Decode, tiff.DecodeConfig, 0, [][]int{
+		{0x49, 0x49, 0x2A, 0x00}, /* little-endian: II*\0 */
+		{0x4D, 0x4D, 0x00, 0x2A}, /* big-endian: MM\0* */
+	}},
 }
 
-func decoderImage(content []byte) func(io.Reader) (image.Image, error) {
-	for _, form := range formats {
+func decoderImage(content []byte) *imageFormat {
+	for i := range formats {
+		form := &formats[i]
 		for _, pat := range form.Patterns {
 			if form.Offset+len(pat) > len(content) {
 				continue
@@ -49,7 +62,7 @@ func decoderImage(content []byte) func(io.Reader) (image.Image, error) {
 				}
 			}
 			if found {
-				return form.Decode
+				return form
 			}
 		}
 	}
@@ -57,23 +70,80 @@ func decoderImage(content []byte) func(io.Reader) (image.Image, error) {
 }
 
 type ImageSlide struct {
-	src image.Image
+	path   string
+	format *imageFormat /* nil for generated images */
+	once   sync.Once
+	src    image.Image /* nil until decoded */
+	err    error
+	bounds image.Rectangle /* read by NewImageSlide */
+
+	Caption MarkupText /* drawn below the image, optional */
+	Line    int        /* of its `@`-line, 0 for generated images */
 }
 
+/* NewImageSlide checks the format and reads the dimensions of the image at `pat`, decoding is deferred to Decode or the first Draw */
 func NewImageSlide(pat string) (*ImageSlide, error) {
-	content, err := os.ReadFile(pat)
+	file, err := os.Open(pat)
 	if err != nil {
 		return nil, err
 	}
-	decoder := decoderImage(content)
-	if decoder == nil {
+	defer file.Close()
+	header := make([]byte, 16)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	format := decoderImage(header[:n])
+	if format == nil {
 		return nil, fmt.Errorf("invalid image-format of %s", pat)
 	}
-	img, err := decoder(bytes.NewBuffer(content))
-	if err != nil {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		return nil, err
 	}
-	return &ImageSlide{src: img}, nil
+	cfg, err := format.DecodeConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pat, err)
+	}
+	return &ImageSlide{path: pat, format: format, bounds: image.Rect(0, 0, cfg.Width, cfg.Height)}, nil
+}
+
+/* Format returns the name of the image-format, like png or jpeg, "" for generated images */
+func (s *ImageSlide) Format() string {
+	if s.format == nil {
+		return ""
+	}
+	return s.format.Name
+}
+
+/* Bounds returns the dimensions of the image, known without decoding it */
+func (s *ImageSlide) Bounds() image.Rectangle {
+	if s.format == nil {
+		return s.src.Bounds()
+	}
+	return s.bounds
+}
+
+/* NewImageSlideFromImage wraps a generated or already decoded image */
+func NewImageSlideFromImage(img image.Image) *ImageSlide {
+	s := &ImageSlide{src: img}
+	s.once.Do(func() {}) /* nothing to decode */
+	return s
+}
+
+/* Decode decodes the image once, later calls return the same result */
+func (s *ImageSlide) Decode() error {
+	s.once.Do(func() {
+		content, err := os.ReadFile(s.path)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.src, s.err = s.format.Decode(bytes.NewReader(content))
+		if s.err != nil {
+			s.err = fmt.Errorf("%s: %w", s.path, s.err)
+		}
+	})
+	return s.err
 }
 
 // positionImage inside W×H (contain). Never exceed the box.
@@ -123,24 +193,259 @@ func positionImage(src image.Rectangle, box image.Rectangle, align Alignment, va
 	return image.Rectangle{box.Min.Add(image.Point{x, y}), box.Min.Add(image.Point{x + w, y + h})}
 }
 
+// cropImage returns the part of `src` which covers W×H when scaled, anchored by align/valign.
+func cropImage(src image.Rectangle, box image.Rectangle, align Alignment, valign VerticalAlignment) image.Rectangle {
+	if src.Empty() || box.Empty() {
+		return src
+	}
+	sw, sh := src.Dx(), src.Dy()
+
+	/* factor */
+	s := max(float64(box.Dx())/float64(sw), float64(box.Dy())/float64(sh))
+
+	/* visible width&height of the source, capped to actual width and height */
+	w := min(int(float64(box.Dx())/s), sw)
+	h := min(int(float64(box.Dy())/s), sh)
+
+	var x, y int
+	switch align {
+	case Left:
+		x = 0
+	case Center:
+		x = (sw - w) / 2
+	case Right:
+		x = sw - w
+	}
+	switch valign {
+	case Top:
+		y = 0
+	case Middle:
+		y = (sh - h) / 2
+	case Bottom:
+		y = sh - h
+	}
+	return image.Rect(x, y, x+w, y+h).Add(src.Min)
+}
+
+func (s ImageScaling) interpolator() xdraw.Interpolator {
+	switch s {
+	case ScaleNearest:
+		return xdraw.NearestNeighbor
+	case ScaleCatmullRom:
+		return xdraw.CatmullRom
+	default:
+		return xdraw.BiLinear
+	}
+}
+
 func (s *ImageSlide) Draw(img draw.Image, bounds image.Rectangle, attr PresConfig) {
-	bounds = attr.Margin.Apply(bounds)
-	imgr := positionImage(s.src.Bounds(), bounds, attr.Align, attr.VAlign)
-	xdraw.BiLinear.Scale(img, imgr, s.src, s.src.Bounds(), draw.Over, nil)
+	if err := s.Decode(); err != nil {
+		warnOnce("image: %v", err)
+		return
+	}
+	bounds = attr.Padding.Apply(bounds)
+	if len(s.Caption) > 0 {
+		band := bounds
+		band.Min.Y = bounds.Max.Y - bounds.Dy()/8
+		bounds.Max.Y = band.Min.Y
+
+		capcfg := attr
+		capcfg.Padding = Margins{}
+		capcfg.VAlign = Top
+		s.Caption.Draw(img, band, capcfg)
+	}
+	if attr.ImageBackground != nil {
+		draw.Draw(img, bounds, attr.ImageBackground, image.Point{}, draw.Src)
+	}
+	imgr, srcr := bounds, s.src.Bounds()
+	switch attr.ImageFit {
+	case FitCover:
+		anchor := Anchor{attr.Align, attr.VAlign}
+		if attr.ImageAnchor != nil {
+			anchor = *attr.ImageAnchor
+		}
+		srcr = cropImage(srcr, bounds, anchor.Align, anchor.VAlign)
+	case FitStretch:
+		/* imgr is the whole box */
+	default:
+		imgr = positionImage(srcr, bounds, attr.Align, attr.VAlign)
+	}
+	if attr.ImageShadow != nil {
+		drawShadow(img, imgr, int(attr.ImageRadius*float64(min(imgr.Dx(), imgr.Dy()))), attr)
+	}
+	if attr.ImageRadius <= 0 && attr.ImageBorder == nil {
+		attr.ImageScaling.interpolator().Scale(img, imgr, s.src, srcr, draw.Over, nil)
+		return
+	}
+
+	scaled := image.NewRGBA(imgr)
+	attr.ImageScaling.interpolator().Scale(scaled, imgr, s.src, srcr, draw.Src, nil)
+	radius := int(attr.ImageRadius * float64(min(imgr.Dx(), imgr.Dy())))
+	inner := imgr
+	if attr.ImageBorder != nil {
+		draw.DrawMask(img, imgr, attr.ImageBorder, image.Point{}, &roundedRect{imgr, radius}, imgr.Min, draw.Over)
+		inner = imgr.Inset(attr.ImageBorderWidth)
+		radius = max(radius-attr.ImageBorderWidth, 0)
+	}
+	draw.DrawMask(img, inner, scaled, inner.Min, &roundedRect{inner, radius}, inner.Min, draw.Over)
+}
+
+/* drawShadow draws a blurred drop shadow of `r`, with corners rounded by `radius`, offset down and to the right */
+func drawShadow(img draw.Image, r image.Rectangle, radius int, attr PresConfig) {
+	blur := attr.ImageShadowBlur
+	mask := image.NewAlpha(r.Inset(-blur))
+	draw.Draw(mask, r, &roundedRect{r, radius}, r.Min, draw.Src)
+	/* two box-blur passes approximate a gaussian closely enough */
+	for range 2 {
+		boxBlur(mask, blur/2)
+	}
+	off := image.Pt(attr.ImageShadowOffset, attr.ImageShadowOffset)
+	draw.DrawMask(img, mask.Rect.Add(off), attr.ImageShadow, image.Point{}, mask, mask.Rect.Min, draw.Over)
+}
+
+/* boxBlur averages every pixel of `m` with its neighbours within `radius`, horizontally then vertically */
+func boxBlur(m *image.Alpha, radius int) {
+	if radius <= 0 {
+		return
+	}
+	r := m.Rect
+	line := make([]int, max(r.Dx(), r.Dy()))
+	blur := func(n int, at func(i int) *uint8) {
+		for i := range n {
+			line[i] = int(*at(i))
+		}
+		sum := 0
+		for i := range min(radius, n) {
+			sum += line[i]
+		}
+		for i := range n {
+			if j := i + radius; j < n {
+				sum += line[j]
+			}
+			if j := i - radius - 1; j >= 0 {
+				sum -= line[j]
+			}
+			*at(i) = uint8(sum / (2*radius + 1))
+		}
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		blur(r.Dx(), func(i int) *uint8 { return &m.Pix[m.PixOffset(r.Min.X+i, y)] })
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		blur(r.Dy(), func(i int) *uint8 { return &m.Pix[m.PixOffset(x, r.Min.Y+i)] })
+	}
+}
+
+/* roundedRect is an alpha-mask of `r` with corners rounded by `radius` */
+type roundedRect struct {
+	r      image.Rectangle
+	radius int
+}
+
+func (rr *roundedRect) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (rr *roundedRect) Bounds() image.Rectangle {
+	return rr.r
+}
+
+func (rr *roundedRect) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(rr.r)) {
+		return color.Alpha{0}
+	}
+	/* distance into the corner-square, if any */
+	var dx, dy int
+	if x < rr.r.Min.X+rr.radius {
+		dx = rr.r.Min.X + rr.radius - x
+	} else if x >= rr.r.Max.X-rr.radius {
+		dx = x - (rr.r.Max.X - rr.radius - 1)
+	}
+	if y < rr.r.Min.Y+rr.radius {
+		dy = rr.r.Min.Y + rr.radius - y
+	} else if y >= rr.r.Max.Y-rr.radius {
+		dy = y - (rr.r.Max.Y - rr.radius - 1)
+	}
+	if dx*dx+dy*dy > rr.radius*rr.radius {
+		return color.Alpha{0}
+	}
+	return color.Alpha{255}
 }
 
+/* drawWatermark draws cfg.Watermark into its corner of the slide filling `bounds` */
+func drawWatermark(img draw.Image, bounds image.Rectangle, cfg PresConfig) {
+	if cfg.Watermark == nil {
+		return
+	}
+	area := bounds.Inset(min(bounds.Dx(), bounds.Dy()) / 50)
+	w, h := int(float64(area.Dx())*cfg.WatermarkSize), int(float64(area.Dy())*cfg.WatermarkSize)
+	var box image.Rectangle
+	switch cfg.WatermarkAnchor.Align {
+	case Left:
+		box.Min.X = area.Min.X
+	case Center:
+		box.Min.X = area.Min.X + (area.Dx()-w)/2
+	case Right:
+		box.Min.X = area.Max.X - w
+	}
+	switch cfg.WatermarkAnchor.VAlign {
+	case Top:
+		box.Min.Y = area.Min.Y
+	case Middle:
+		box.Min.Y = area.Min.Y + (area.Dy()-h)/2
+	case Bottom:
+		box.Min.Y = area.Max.Y - h
+	}
+	box.Max = box.Min.Add(image.Pt(w, h))
+
+	r := positionImage(cfg.Watermark.Bounds(), box, cfg.WatermarkAnchor.Align, cfg.WatermarkAnchor.VAlign)
+	if r.Empty() {
+		return
+	}
+	scaled := image.NewRGBA(r)
+	cfg.ImageScaling.interpolator().Scale(scaled, r, cfg.Watermark, cfg.Watermark.Bounds(), draw.Src, nil)
+	mask := image.NewUniform(color.Alpha16{uint16(cfg.WatermarkOpacity * 0xffff)})
+	draw.DrawMask(img, r, scaled, r.Min, mask, image.Point{}, draw.Over)
+}
+
+/* FinalSlide returns the slide closing the presentation, showing cfg.FinalText */
 func FinalSlide(cfg PresConfig) Slide {
-	cfg.Background = image.NewUniform(color.Gray{50})
-	cfg.Foreground = image.NewUniform(color.Gray{200})
+	cfg.Background = cfg.FinalBg
+	cfg.Foreground = cfg.FinalFg
+	cfg.AutoForeground = false
 	cfg.FontSize = 3
 	cfg.VAlign = Top
 
-	return Slide{cfg, "", []SlideContent{
-		MarkupText{
-			Markup{
-				Attr: Bold,
-				Text: "End of Presentation",
-			},
-		},
-	}}
+	var text MarkupBuilder
+	text.Feed(cfg.FinalText)
+	return Slide{Conf: cfg, Content: []SlideContent{text.Text()}}
+}
+
+/* DecodeImages decodes all images of the presentation concurrently, instead of on their first draw */
+func (p *Presentation) DecodeImages() error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	sem := make(chan struct{}, runtime.NumCPU())
+	for _, slide := range p.Slides {
+		for _, cnt := range slide.Content {
+			img, ok := cnt.(*ImageSlide)
+			if !ok {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				if err := img.Decode(); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+				<-sem
+			}()
+		}
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
@@ -0,0 +1,134 @@
+package slab
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func TestDecoderImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"tiff little-endian", []byte("II*\x00\x08\x00\x00\x00"), "tiff"},
+		{"tiff big-endian", []byte("MM\x00*\x00\x00\x00\x08"), "tiff"},
+		{"tiff mixed byte-order", []byte("II\x00*\x08\x00\x00\x00"), ""},
+		{"tiff truncated", []byte("MM\x00"), ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), "png"},
+		{"gif", []byte("GIF89a"), "gif"},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01"), "jpeg"},
+		{"text", []byte("hello, world"), ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if form := decoderImage(tt.header); form != nil {
+			got = form.Name
+		}
+		if got != tt.want {
+			t.Errorf("%s: format %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeTIFF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	form := decoderImage(buf.Bytes())
+	if form == nil || form.Name != "tiff" {
+		t.Fatalf("encoded TIFF is detected as %v", form)
+	}
+	img, err := form.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Errorf("bounds %v, want 3×2", img.Bounds())
+	}
+}
+
+func TestCoverAnchor(t *testing.T) {
+	/* red left half, blue right half, cropped into a square */
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 40, 10))
+	draw.Draw(src, image.Rect(0, 0, 20, 10), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(20, 0, 40, 10), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	tests := []struct {
+		attrs []string
+		want  color.RGBA
+	}{
+		{[]string{"align=left"}, red},
+		{[]string{"align=right"}, blue},
+		{[]string{"align=left", "image-anchor=right"}, blue},
+		{[]string{"align=right", "image-anchor=left"}, red},
+	}
+	for _, tt := range tests {
+		cfg := defaultConf()
+		cfg.Padding = Margins{}
+		for _, attr := range append([]string{"image-fit=cover"}, tt.attrs...) {
+			if err := cfg.AddAttribute(attr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		NewImageSlideFromImage(src).Draw(dst, dst.Rect, cfg)
+		if got := dst.RGBAAt(5, 5); got != tt.want {
+			t.Errorf("%v: center is %v, want %v", tt.attrs, got, tt.want)
+		}
+	}
+}
+
+func TestImageSlideBounds(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		format string
+		encode func(io.Writer, image.Image) error
+	}{
+		{"png", png.Encode},
+		{"tiff", func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) }},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := tt.encode(&buf, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "image."+tt.format)
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewImageSlide(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		/* known without reading the file again */
+		os.Remove(path)
+		if s.Format() != tt.format || s.Bounds() != image.Rect(0, 0, 3, 2) {
+			t.Errorf("%s: format %q, bounds %v", tt.format, s.Format(), s.Bounds())
+		}
+
+		/* a valid header followed by garbage */
+		if err := os.WriteFile(path, buf.Bytes()[:16], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewImageSlide(path); err == nil {
+			t.Errorf("%s: truncated image is no error", tt.format)
+		}
+	}
+
+	generated := NewImageSlideFromImage(image.NewGray(image.Rect(0, 0, 5, 4)))
+	if generated.Format() != "" || generated.Bounds() != image.Rect(0, 0, 5, 4) {
+		t.Errorf("generated: format %q, bounds %v", generated.Format(), generated.Bounds())
+	}
+}
@@ -0,0 +1,220 @@
+package slab
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"unicode"
+
+	"golang.org/x/image/math/fixed"
+)
+
+type WritingMode int
+
+const (
+	WritingHorizontal WritingMode = iota
+	WritingVertical               /* top-to-bottom columns, right-to-left */
+)
+
+/* vglyph is a glyph in vertical text */
+type vglyph struct {
+	attr    MarkupAttribute
+	r       rune
+	upright bool
+	advance fixed.Int26_6 /* downwards */
+	math    *mathBox      /* set upright in place of `r` */
+}
+
+/* verticalLayout is MarkupText set in columns at a specific size */
+type verticalLayout struct {
+	columns  [][]vglyph /* right-to-left, empty for paragraph-breaks */
+	colWidth fixed.Int26_6
+	height   fixed.Int26_6 /* of the longest column */
+}
+
+/* isCJK reports whether `r` is set upright in vertical text regardless of RotateLatin */
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo) ||
+		r >= 0x3000 && r <= 0x303F || /* CJK punctuation */
+		r >= 0xFF00 && r <= 0xFFEF /* full-width forms */
+}
+
+/* verticalLayout sets `m` in columns of `bounds`-height, ok is false if it overflows `bounds` */
+func (m MarkupText) verticalLayout(bounds image.Rectangle, size float64, cfg PresConfig) (lay verticalLayout, ok bool) {
+	var col []vglyph
+	var y fixed.Int26_6
+	height := fixed.I(bounds.Dy())
+	/* place appends `g` to the column, false if it exceeds an empty column */
+	place := func(g vglyph) bool {
+		if y+g.advance > height {
+			if len(col) == 0 {
+				return false
+			}
+			lay.columns = append(lay.columns, col)
+			col, y = nil, 0
+			if unicode.IsSpace(g.r) {
+				return true
+			}
+		}
+		col = append(col, g)
+		y += g.advance
+		lay.height = max(lay.height, y)
+		return true
+	}
+	for _, part := range m {
+		if part.Attr.has(Math) {
+			box := part.Attr.mathBox(part.Text, size, cfg)
+			lay.colWidth = max(lay.colWidth, box.width)
+			if !place(vglyph{attr: part.Attr, upright: true, advance: box.ascent + box.descent, math: &box}) {
+				return lay, false
+			}
+			continue
+		}
+		face := part.Attr.cachedFace(size, cfg)
+		met := face.Metrics()
+		lay.colWidth = max(lay.colWidth, met.Height)
+		for _, r := range part.Text {
+			if r == '\n' {
+				/* an empty column separates paragraphs */
+				lay.columns = append(lay.columns, col, nil)
+				col, y = nil, 0
+				continue
+			}
+			if r == lineBreak {
+				lay.columns = append(lay.columns, col)
+				col, y = nil, 0
+				continue
+			}
+			g := vglyph{attr: part.Attr, r: r, upright: isCJK(r) || !cfg.RotateLatin}
+			if g.upright {
+				g.advance = met.Height
+			} else {
+				g.advance, _ = face.GlyphAdvance(r)
+			}
+			if !place(g) {
+				/* a single glyph exceeds the column */
+				return lay, false
+			}
+		}
+	}
+	if len(col) > 0 {
+		lay.columns = append(lay.columns, col)
+	}
+	ok = lay.colWidth*fixed.Int26_6(len(lay.columns)) <= fixed.I(bounds.Dx())
+	return lay, ok
+}
+
+/* fitVertical finds the largest size at which `m` fits `bounds` in columns, within min- and max-font-size */
+func (m MarkupText) fitVertical(bounds image.Rectangle, cfg PresConfig) (size float64, lay verticalLayout) {
+	if len(m) == 0 {
+		return
+	}
+	size = largestSize(func(size float64) bool {
+		l, ok := m.verticalLayout(bounds, size, cfg)
+		if ok {
+			lay = l
+		}
+		return ok
+	})
+	if size == 0 {
+		/* too long even at the smallest size, draw it anyway and let it overflow */
+		size = 1
+		lay, _ = m.verticalLayout(bounds, size, cfg)
+	}
+	if cfg.MaxFontSize > 0 && size > cfg.points(cfg.MaxFontSize, cfg.MaxFontUnit, bounds) {
+		size = cfg.points(cfg.MaxFontSize, cfg.MaxFontUnit, bounds)
+		lay, _ = m.verticalLayout(bounds, size, cfg)
+	}
+	if cfg.MinFontSize > 0 && size < cfg.points(cfg.MinFontSize, cfg.MinFontUnit, bounds) {
+		size = cfg.points(cfg.MinFontSize, cfg.MinFontUnit, bounds)
+		lay, _ = m.verticalLayout(bounds, size, cfg)
+	}
+	return
+}
+
+/* drawVertical draws `m` top-to-bottom in columns from right to left, underline and strikethrough are not drawn */
+func (m MarkupText) drawVertical(ctx context.Context, img draw.Image, bounds image.Rectangle, cfg PresConfig) error {
+	var size float64
+	var lay verticalLayout
+	if cfg.FontSize != 0 {
+		size = cfg.points(cfg.FontSize, cfg.FontUnit, bounds)
+		lay, _ = m.verticalLayout(bounds, size, cfg)
+	} else {
+		size, lay = m.fitVertical(bounds, cfg)
+	}
+	outline := max(int(cfg.pixels(size)/30), 1)
+
+	/* the first column starts at the right */
+	total := lay.colWidth * fixed.Int26_6(len(lay.columns))
+	right := fixed.I(bounds.Dx())
+	switch cfg.Align {
+	case Left:
+		right = total
+	case Center:
+		right = (fixed.I(bounds.Dx()) + total) / 2
+	}
+	/* the columns hang from the top, valign moves them down by the space below the longest one */
+	var top fixed.Int26_6
+	switch cfg.VAlign {
+	case Middle:
+		top = (fixed.I(bounds.Dy()) - lay.height) / 2
+	case Bottom:
+		top = fixed.I(bounds.Dy()) - lay.height
+	}
+
+	for i, col := range lay.columns {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("drawing text: %w", err)
+		}
+		center := right - lay.colWidth*fixed.Int26_6(i) - lay.colWidth/2
+		y := top
+		for _, g := range col {
+			if g.attr.has(Hidden) {
+				y += g.advance
+				continue
+			}
+			fill := cfg.fill(g.attr)
+			if g.math != nil {
+				/* math is set upright, centered in the column */
+				dot := fixed.Point26_6{X: center - g.math.width/2, Y: y + g.math.ascent}
+				g.math.draw(img, bounds.Min, dot, outline, fill, cfg)
+				y += g.advance
+				continue
+			}
+			face := g.attr.face(size, cfg)
+			met := face.Metrics()
+			if g.upright {
+				adv, _ := face.GlyphAdvance(g.r)
+				dot := fixed.Point26_6{X: center - adv/2, Y: y + met.Ascent}
+				dr, mask, maskp, _, ok := face.Glyph(dot, g.r)
+				if ok {
+					drawGlyph(img, dr.Add(bounds.Min), mask, maskp, outline, fill, cfg)
+				}
+			} else {
+				/* rotated clockwise: the baseline runs downwards, ascenders point right */
+				dr, mask, maskp, _, ok := face.Glyph(fixed.Point26_6{}, g.r)
+				if ok {
+					rot := rotateGlyph(dr, mask, maskp)
+					baseline := center - (met.Ascent-met.Descent)/2
+					off := image.Pt(baseline.Round(), y.Round()).Add(bounds.Min)
+					drawGlyph(img, rot.Rect.Add(off), rot, rot.Rect.Min, outline, fill, cfg)
+				}
+			}
+			y += g.advance
+		}
+	}
+	return nil
+}
+
+/* rotateGlyph rotates the glyph-mask drawn at `dr` clockwise around its origin */
+func rotateGlyph(dr image.Rectangle, mask image.Image, maskp image.Point) *image.Alpha {
+	rot := image.NewAlpha(image.Rect(-dr.Max.Y, dr.Min.X, -dr.Min.Y, dr.Max.X))
+	for y := dr.Min.Y; y < dr.Max.Y; y++ {
+		for x := dr.Min.X; x < dr.Max.X; x++ {
+			_, _, _, a := mask.At(maskp.X+x-dr.Min.X, maskp.Y+y-dr.Min.Y).RGBA()
+			rot.Pix[rot.PixOffset(-y-1, x)] = uint8(a >> 8)
+		}
+	}
+	return rot
+}
@@ -0,0 +1,77 @@
+package slab
+
+import (
+	"context"
+	"image"
+	"testing"
+)
+
+func TestFitVertical(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 400)
+	text := MarkupText{{0, "vertical text set in columns"}}
+	cfg := defaultConf()
+	cfg.WritingMode = WritingVertical
+
+	size, lay := text.fitVertical(bounds, cfg)
+	if _, ok := text.verticalLayout(bounds, size, cfg); !ok {
+		t.Fatalf("does not fit at the fitted size %v", size)
+	}
+	if _, ok := text.verticalLayout(bounds, size+0.5, cfg); ok {
+		t.Errorf("fits at %v, larger than the fitted size %v", size+0.5, size)
+	}
+	if lay.height <= 0 || lay.height.Ceil() > bounds.Dy() {
+		t.Errorf("height %v outside of %d", lay.height, bounds.Dy())
+	}
+
+	tests := []struct {
+		name     string
+		min, max float64
+		want     float64
+	}{
+		{"max-font-size", 0, size / 2, size / 2},
+		{"min-font-size", size * 2, 0, size * 2},
+		{"within", 1, size * 4, size},
+	}
+	for _, tt := range tests {
+		c := cfg
+		c.MinFontSize, c.MinFontUnit = tt.min, FontPoint
+		c.MaxFontSize, c.MaxFontUnit = tt.max, FontPoint
+		if got, _ := text.fitVertical(bounds, c); got != tt.want {
+			t.Errorf("%s: size %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerticalVAlign(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 400)
+	text := MarkupText{{0, "abc"}}
+	cfg := defaultConf()
+	cfg.WritingMode = WritingVertical
+	cfg.FontSize, cfg.FontUnit = 30, FontPoint
+
+	/* inkTop returns the first row drawn to */
+	inkTop := func(valign VerticalAlignment) int {
+		img := image.NewRGBA(bounds)
+		c := cfg
+		c.VAlign = valign
+		if err := text.drawVertical(context.Background(), img, bounds, c); err != nil {
+			t.Fatal(err)
+		}
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if img.RGBAAt(x, y).A != 0 {
+					return y
+				}
+			}
+		}
+		t.Fatalf("valign %v draws nothing", valign)
+		return 0
+	}
+	top, middle, bottom := inkTop(Top), inkTop(Middle), inkTop(Bottom)
+	if !(top < middle && middle < bottom) {
+		t.Errorf("ink starts at %d, %d and %d for top, middle and bottom", top, middle, bottom)
+	}
+	if top > 20 || bottom < bounds.Dy()/2 {
+		t.Errorf("top starts at %d, bottom at %d", top, bottom)
+	}
+}